@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+)
+
+// Build metadata, set with -ldflags "-X main.version=v1.2.3 -X main.commit=abc123 -X main.buildDate=2024-01-01T00:00:00Z"
+// Values left empty fall back to what the Go toolchain recorded in the binary
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// buildInfo describes the running binary
+type buildInfo struct {
+	Version   string
+	Commit    string
+	Date      string
+	Modified  bool
+	GoVersion string
+}
+
+// readBuildInfo combines the ldflags metadata with the module and VCS details from debug.ReadBuildInfo
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, Date: buildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// versionCommand prints the build information
+func versionCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("version", stderr)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+
+	info := readBuildInfo()
+	commit := info.Commit
+	if info.Modified {
+		commit += " (modified)"
+	}
+	fmt.Fprintf(stdout, "echo-server %s\n", info.Version)
+	fmt.Fprintf(stdout, "  commit:   %s\n", commit)
+	fmt.Fprintf(stdout, "  built:    %s\n", info.Date)
+	fmt.Fprintf(stdout, "  go:       %s\n", info.GoVersion)
+	fmt.Fprintf(stdout, "  platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// configPrintCommand prints the effective configuration as KEY=value lines with secrets redacted
+func configPrintCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("config print", stderr)
+	load := configFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+
+	cfg, err := load()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(stdout, "%s=%s\n", s.Key, s.Value)
+	}
+
+	// Print the configuration even when it is invalid, so the problems can be seen in context
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// keygenCommand prints random hex-encoded API keys, one per line
+func keygenCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("keygen", stderr)
+	count := fs.Int("n", 1, "number of keys to generate")
+	size := fs.Int("bytes", 32, "random bytes per key, at least 16")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+	if *count < 1 || *size < 16 {
+		fmt.Fprintln(stderr, "keygen: -n must be at least 1 and -bytes at least 16")
+		return 2
+	}
+
+	buf := make([]byte, *size)
+	for range *count {
+		// crypto/rand.Read never returns an error
+		_, _ = rand.Read(buf)
+		fmt.Fprintln(stdout, hex.EncodeToString(buf))
+	}
+	return 0
+}
+
+// healthcheckCommand probes a running instance, exiting 0 on a 2xx response and 1 otherwise
+// The target is derived from the same configuration the server loads, so it works without flags in a container
+func healthcheckCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("healthcheck", stderr)
+	load := configFlags(fs)
+	url := fs.String("url", "", "URL to probe instead of the configured listener")
+	path := fs.String("path", "/readyz", "path to probe on the configured listener")
+	timeout := fs.Duration("timeout", 3*time.Second, "time allowed for the probe")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	target := *url
+	if target == "" {
+		cfg, err := load()
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+			return 1
+		}
+		base, transport, err := healthcheckTarget(cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, "healthcheck: %v\n", err)
+			return 1
+		}
+		target = base + *path
+		client.Transport = transport
+	}
+
+	resp, err := client.Get(target)
+	if err != nil {
+		fmt.Fprintf(stderr, "unhealthy: %v\n", err)
+		return 1
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Fprintf(stderr, "unhealthy: %s returned %s\n", target, resp.Status)
+		return 1
+	}
+	fmt.Fprintf(stdout, "healthy: %s returned %s\n", target, resp.Status)
+	return 0
+}
+
+// healthcheckTarget returns the base URL and transport for probing the local instance
+// The admin listener is preferred because it never requires TLS or PROXY protocol headers
+func healthcheckTarget(cfg *config.Config) (string, http.RoundTripper, error) {
+	if cfg.AdminPort != "" {
+		return "http://" + net.JoinHostPort("127.0.0.1", cfg.AdminPort), nil, nil
+	}
+	if cfg.ProxyProtocol {
+		return "", nil, errors.New("the main listener requires PROXY protocol headers, set ADMIN_PORT or -url")
+	}
+
+	addr := cfg.Address()
+	if socket, ok := strings.CutPrefix(addr, "unix:"); ok {
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		return "http://localhost", transport, nil
+	}
+	if strings.HasPrefix(addr, "systemd") {
+		return "", nil, errors.New("socket-activated listeners have no known address, set ADMIN_PORT or -url")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	// Wildcard addresses accept loopback connections
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		// The certificate names the public host rather than loopback, and only availability is being checked
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		return "https://" + net.JoinHostPort(host, port), transport, nil
+	}
+	return "http://" + net.JoinHostPort(host, port), nil, nil
+}
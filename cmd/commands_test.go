@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+)
+
+func TestVersionCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		commit    string
+		buildDate string
+		want      []string
+	}{
+		{
+			name: "without ldflags",
+			want: []string{"echo-server ", "commit:", "go:       go1.", "platform:"},
+		},
+		{
+			name:      "ldflags take precedence",
+			version:   "v1.2.3",
+			commit:    "abc1234",
+			buildDate: "2024-01-01T00:00:00Z",
+			want:      []string{"echo-server v1.2.3\n", "commit:   abc1234", "built:    2024-01-01T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldVersion, oldCommit, oldDate := version, commit, buildDate
+			t.Cleanup(func() { version, commit, buildDate = oldVersion, oldCommit, oldDate })
+			version, commit, buildDate = tt.version, tt.commit, tt.buildDate
+
+			var stdout bytes.Buffer
+			if code := run([]string{"version"}, &stdout, &bytes.Buffer{}); code != 0 {
+				t.Fatalf("run(version) = %d, want 0", code)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("version output = %q, want it to contain %q", stdout.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigPrintCommand(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("API_KEYS", "secret-key-1,secret-key-2")
+	t.Setenv("METRICS_TOKEN", "secret-token")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"config", "print", "-port", "9000"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run(config print) = %d, want 0 (stderr %q)", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"PORT=9000\n",
+		"AUTH_ENABLED=true\n",
+		"API_KEYS=[REDACTED] (2 keys)\n",
+		"METRICS_TOKEN=[REDACTED]\n",
+		"METRICS_PASSWORD=\n",
+		"READ_TIMEOUT=15s\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("config print output missing %q", want)
+		}
+	}
+	for _, secret := range []string{"secret-key-1", "secret-key-2", "secret-token"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("config print output leaks %q", secret)
+		}
+	}
+
+	// Invalid settings are printed and reported
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"config", "print", "-log-level", "loud"}, &stdout, &stderr); code != 1 {
+		t.Errorf("run(config print) with an invalid level = %d, want 1", code)
+	}
+	if !strings.Contains(stdout.String(), "LOG_LEVEL=loud\n") || !strings.Contains(stderr.String(), "LOG_LEVEL: unknown level") {
+		t.Errorf("stdout = %q, stderr = %q, want the level printed and reported", stdout.String(), stderr.String())
+	}
+}
+
+func TestKeygenCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantKeys int
+		wantLen  int
+	}{
+		{name: "default", wantCode: 0, wantKeys: 1, wantLen: 64},
+		{name: "several longer keys", args: []string{"-n", "3", "-bytes", "48"}, wantCode: 0, wantKeys: 3, wantLen: 96},
+		{name: "too short", args: []string{"-bytes", "8"}, wantCode: 2},
+		{name: "no keys", args: []string{"-n", "0"}, wantCode: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			code := run(append([]string{"keygen"}, tt.args...), &stdout, &bytes.Buffer{})
+			if code != tt.wantCode {
+				t.Fatalf("run(keygen) = %d, want %d", code, tt.wantCode)
+			}
+
+			keys := strings.Fields(stdout.String())
+			if len(keys) != tt.wantKeys {
+				t.Fatalf("generated %d keys, want %d", len(keys), tt.wantKeys)
+			}
+			seen := make(map[string]bool)
+			for _, key := range keys {
+				if len(key) != tt.wantLen {
+					t.Errorf("key %q has length %d, want %d", key, len(key), tt.wantLen)
+				}
+				if seen[key] {
+					t.Errorf("duplicate key %q", key)
+				}
+				seen[key] = true
+			}
+		})
+	}
+}
+
+func TestHealthcheckCommand(t *testing.T) {
+	ready := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" && r.URL.Path != "/livez" {
+			http.NotFound(w, r)
+			return
+		}
+		if !ready && r.URL.Path == "/readyz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	tests := []struct {
+		name       string
+		args       []string
+		ready      bool
+		wantCode   int
+		wantOutput string
+	}{
+		{name: "ready", args: []string{"-port", port}, ready: true, wantCode: 0, wantOutput: "healthy"},
+		{name: "not ready", args: []string{"-port", port}, ready: false, wantCode: 1, wantOutput: "503 Service Unavailable"},
+		{name: "custom path", args: []string{"-port", port, "-path", "/livez"}, ready: false, wantCode: 0, wantOutput: "healthy"},
+		{name: "admin port preferred", args: []string{"-port", "1", "-admin-port", port}, ready: true, wantCode: 0, wantOutput: "healthy"},
+		{name: "explicit url", args: []string{"-url", ts.URL + "/missing"}, ready: true, wantCode: 1, wantOutput: "404 Not Found"},
+		{name: "nothing listening", args: []string{"-url", "http://127.0.0.1:1/readyz"}, ready: true, wantCode: 1, wantOutput: "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			ready = tt.ready
+
+			var stdout, stderr bytes.Buffer
+			code := run(append([]string{"healthcheck"}, tt.args...), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Errorf("run(healthcheck) = %d, want %d (stderr %q)", code, tt.wantCode, stderr.String())
+			}
+			if out := stdout.String() + stderr.String(); !strings.Contains(out, tt.wantOutput) {
+				t.Errorf("output = %q, want it to contain %q", out, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestHealthcheckTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		wantBase  string
+		wantTLS   bool
+		wantError bool
+	}{
+		{name: "port", cfg: &config.Config{Port: "8080"}, wantBase: "http://127.0.0.1:8080"},
+		{name: "IPv6 wildcard", cfg: &config.Config{ListenAddr: "[::]:8080"}, wantBase: "http://[::1]:8080"},
+		{name: "specific host", cfg: &config.Config{ListenAddr: "10.0.0.5:8080"}, wantBase: "http://10.0.0.5:8080"},
+		{name: "admin port", cfg: &config.Config{Port: "8080", AdminPort: "9090", ProxyProtocol: true}, wantBase: "http://127.0.0.1:9090"},
+		{name: "TLS", cfg: &config.Config{Port: "8443", TLSCertFile: "c.pem", TLSKeyFile: "k.pem"}, wantBase: "https://127.0.0.1:8443", wantTLS: true},
+		{name: "unix socket", cfg: &config.Config{ListenAddr: "unix:/run/echo.sock"}, wantBase: "http://localhost"},
+		{name: "systemd", cfg: &config.Config{ListenAddr: "systemd"}, wantError: true},
+		{name: "PROXY protocol", cfg: &config.Config{Port: "8080", ProxyProtocol: true}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, transport, err := healthcheckTarget(tt.cfg)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("healthcheckTarget() = %q, want an error", base)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("healthcheckTarget() error = %v", err)
+			}
+			if base != tt.wantBase {
+				t.Errorf("base = %q, want %q", base, tt.wantBase)
+			}
+			if tt.wantTLS {
+				tr, ok := transport.(*http.Transport)
+				if !ok || tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+					t.Error("TLS target without a transport that accepts the server certificate")
+				}
+			}
+		})
+	}
+}
@@ -1,47 +1,229 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/logging"
+	"github.com/lkendrickd/echo-server/internal/middleware"
+	"github.com/lkendrickd/echo-server/internal/mock"
 	"github.com/lkendrickd/echo-server/internal/server"
 )
 
+const usageText = `Usage: echo-server [command] [flags]
+
+Commands:
+  serve         Start the server (default)
+  version       Print build information
+  config print  Print the effective configuration with secrets redacted
+  keygen        Generate random API keys
+  healthcheck   Probe a running instance, exiting 0 if it is ready and 1 otherwise
+
+Run "echo-server <command> -h" for the flags of a command.
+`
+
 func main() {
-	// Load configuration from environment variables
-	cfg := config.New()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches args to a subcommand and returns the process exit code
+// Arguments that start with a flag run serve, so "echo-server -port 9000" keeps working
+func run(args []string, stdout, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	// Set the log level based on the config
-	slogLevel := setLogLevel(cfg.LogLevel)
+	switch name {
+	case "serve":
+		return serve(args, stdout, stderr)
+	case "version":
+		return versionCommand(args, stdout, stderr)
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			fmt.Fprintln(stderr, `Usage: echo-server config print [flags]`)
+			return 2
+		}
+		return configPrintCommand(args[1:], stdout, stderr)
+	case "keygen":
+		return keygenCommand(args, stdout, stderr)
+	case "healthcheck":
+		return healthcheckCommand(args, stdout, stderr)
+	case "help":
+		fmt.Fprint(stdout, usageText)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usageText)
+		return 2
+	}
+}
 
-	// Initialize the logger with the determined log level
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slogLevel}))
+// serve loads and validates the configuration, then runs the server until SIGINT or SIGTERM
+func serve(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("serve", stderr)
+	load := configFlags(fs)
+	checkConfig := fs.Bool("check-config", false, "validate the configuration and exit without serving")
+	if err := fs.Parse(args); err != nil {
+		return flagExitCode(err)
+	}
+
+	// Load configuration from defaults, the config file, environment variables and flags
+	cfg, err := load()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// Refuse to start with invalid settings rather than silently falling back to defaults
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *checkConfig {
+		// The server reads the mock routes file when it starts, so check it too
+		if cfg.MockRoutesFile != "" {
+			if _, _, err := mock.Load(cfg.MockRoutesFile); err != nil {
+				fmt.Fprintf(stderr, "invalid mock routes: %v\n", err)
+				return 1
+			}
+		}
+		fmt.Fprintln(stdout, "configuration OK")
+		return 0
+	}
+
+	// Start at the configured level, the LevelVar lets signals and the admin endpoint change it at runtime
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(setLogLevel(cfg.LogLevel))
+
+	// Initialize the logger with the level variable, tagging request-scoped records with their request ID
+	handler, logOutput, err := logging.New(logOptions(cfg, logLevel, stdout, stderr))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open log output: %v\n", err)
+		return 1
+	}
+	defer logOutput.Close()
+	logger := slog.New(middleware.NewRequestIDLogHandler(handler))
 
 	// Log configuration (without sensitive data)
 	logger.Info("configuration loaded",
+		"version", readBuildInfo().Version,
 		"port", cfg.Port,
+		"listen_addr", cfg.Address(),
 		"log_level", cfg.LogLevel,
 		"auth_enabled", cfg.AuthEnabled,
 		"api_key_count", cfg.APIKeyCount(),
+		"admin_port", cfg.AdminPort,
+		"metrics_enabled", !cfg.MetricsDisabled,
 	)
 
-	// Warn if auth is enabled but no keys configured
-	if cfg.AuthEnabled && !cfg.HasAPIKeys() {
-		logger.Warn("authentication enabled but no API keys configured")
-	}
-
 	// Initialize the HTTP server mux
 	mux := http.NewServeMux()
 
+	// Cancel the server context on SIGINT or SIGTERM to start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Restore default signal handling once shutdown begins so a second signal exits immediately
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// SIGUSR1 and SIGUSR2 step the log level without a restart
+	watchLogLevelSignals(ctx, logLevel, logger)
+
 	// Create and start the server
-	s := server.NewServer(logger, mux, fmt.Sprintf(":%s", cfg.Port), cfg)
-	if err := s.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	s := server.NewServer(logger, mux, cfg.Address(), cfg)
+	s.SetLogLevel(logLevel)
+	if err := s.Start(ctx); err != nil {
+		logger.Error("server failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors instead of exiting
+func newFlagSet(name string, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("echo-server "+name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	return fs
+}
+
+// flagExitCode maps a flag parsing error to an exit code, -h succeeds
+func flagExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
+
+// configFlags registers the configuration flags on fs and returns a loader to call after parsing
+// Flags that are set take precedence over the config file and environment variables
+func configFlags(fs *flag.FlagSet) func() (*config.Config, error) {
+	configFile := fs.String("config", "", "path to a YAML, TOML or JSON config file, overrides CONFIG_FILE")
+	port := fs.String("port", "", "port to listen on")
+	listenAddr := fs.String("listen-addr", "", "listen address overriding -port: host:port, unix:/path.sock or systemd[:name]")
+	adminPort := fs.String("admin-port", "", "port for the admin listener")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+
+	return func() (*config.Config, error) {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "port":
+				cfg.Port = *port
+				cfg.SetSource("PORT", config.SourceFlag)
+			case "listen-addr":
+				cfg.ListenAddr = *listenAddr
+				cfg.SetSource("LISTEN_ADDR", config.SourceFlag)
+			case "admin-port":
+				cfg.AdminPort = *adminPort
+				cfg.SetSource("ADMIN_PORT", config.SourceFlag)
+			case "log-level":
+				cfg.LogLevel = *logLevel
+				cfg.SetSource("LOG_LEVEL", config.SourceFlag)
+			}
+		})
+		return cfg, nil
+	}
+}
+
+// logOptions maps the log settings in cfg to logging options
+func logOptions(cfg *config.Config, level slog.Leveler, stdout, stderr io.Writer) logging.Options {
+	opts := logging.Options{
+		Format:     cfg.LogFormat,
+		Output:     cfg.LogOutput,
+		MaxSize:    cfg.LogMaxSize,
+		MaxBackups: cfg.LogMaxBackups,
+		Level:      level,
+		AddSource:  cfg.LogSource,
+		Stdout:     stdout,
+		Stderr:     stderr,
+	}
+	for _, attr := range []slog.Attr{
+		slog.String("service", cfg.LogService),
+		slog.String("environment", cfg.LogEnvironment),
+		slog.String("instance_id", cfg.LogInstanceID),
+	} {
+		if attr.Value.String() != "" {
+			opts.Attrs = append(opts.Attrs, attr)
+		}
 	}
+	return opts
 }
 
 // setLogLevel sets the log level based on the provided string
@@ -56,7 +238,7 @@ func setLogLevel(level string) slog.Level {
 	case "error":
 		return slog.LevelError
 	default:
-		fmt.Printf("Unknown log level: %s, defaulting to info\n", level)
+		// Validate rejects unknown levels before the logger is built
 		return slog.LevelInfo
 	}
 }
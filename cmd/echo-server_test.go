@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/logging"
 )
 
 func TestSetLogLevel(t *testing.T) {
@@ -68,3 +76,184 @@ func TestSetLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  port: \"9090\"\n  admin_port: \"9091\"\nlogging:\n  level: warn\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		wantPort       string
+		wantAdminPort  string
+		wantLevel      string
+		wantPortSource string
+	}{
+		{
+			name:           "file only",
+			args:           []string{"-config", path},
+			wantPort:       "9090",
+			wantAdminPort:  "9091",
+			wantLevel:      "warn",
+			wantPortSource: config.SourceFile,
+		},
+		{
+			name:           "environment overrides the file",
+			args:           []string{"-config", path},
+			env:            map[string]string{"PORT": "7070"},
+			wantPort:       "7070",
+			wantAdminPort:  "9091",
+			wantLevel:      "warn",
+			wantPortSource: config.SourceEnv,
+		},
+		{
+			name:           "flags override the environment",
+			args:           []string{"-config", path, "-port", "6060", "-log-level", "debug"},
+			env:            map[string]string{"PORT": "7070", "LOG_LEVEL": "error"},
+			wantPort:       "6060",
+			wantAdminPort:  "9091",
+			wantLevel:      "debug",
+			wantPortSource: config.SourceFlag,
+		},
+		{
+			name:           "CONFIG_FILE without the flag",
+			env:            map[string]string{"CONFIG_FILE": path},
+			wantPort:       "9090",
+			wantAdminPort:  "9091",
+			wantLevel:      "warn",
+			wantPortSource: config.SourceFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			fs := newFlagSet("test", io.Discard)
+			load := configFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			cfg, err := load()
+			if err != nil {
+				t.Fatalf("load() error = %v", err)
+			}
+
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %q, want %q", cfg.Port, tt.wantPort)
+			}
+			if cfg.AdminPort != tt.wantAdminPort {
+				t.Errorf("AdminPort = %q, want %q", cfg.AdminPort, tt.wantAdminPort)
+			}
+			if cfg.LogLevel != tt.wantLevel {
+				t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, tt.wantLevel)
+			}
+			if got := cfg.Source("PORT"); got != tt.wantPortSource {
+				t.Errorf("Source(PORT) = %q, want %q", got, tt.wantPortSource)
+			}
+		})
+	}
+}
+
+func TestLogOptions(t *testing.T) {
+	cfg := &config.Config{LogFormat: "logfmt", LogOutput: "stderr", LogSource: true, LogService: "echo", LogInstanceID: "echo-1"}
+	var stdout, stderr bytes.Buffer
+
+	h, _, err := logging.New(logOptions(cfg, slog.LevelDebug, &stdout, &stderr))
+	if err != nil {
+		t.Fatalf("logging.New() error = %v", err)
+	}
+	slog.New(h).Debug("hello")
+
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", stdout.String())
+	}
+	for _, want := range []string{"level=DEBUG", "msg=hello", "source=", "service=echo instance_id=echo-1"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want it to contain %q", stderr.String(), want)
+		}
+	}
+	if strings.Contains(stderr.String(), "environment=") {
+		t.Errorf("stderr = %q, want unset attributes left out", stderr.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "help", args: []string{"help"}, wantCode: 0, wantStdout: "Commands:"},
+		{name: "unknown command", args: []string{"launch"}, wantCode: 2, wantStderr: `unknown command "launch"`},
+		{name: "config without print", args: []string{"config"}, wantCode: 2, wantStderr: "Usage: echo-server config print"},
+		{name: "serve help", args: []string{"serve", "-h"}, wantCode: 0, wantStderr: "-check-config"},
+		{name: "bad flag", args: []string{"-no-such-flag"}, wantCode: 2, wantStderr: "flag provided but not defined"},
+		{name: "check-config by default", args: []string{"-check-config"}, wantCode: 0, wantStdout: "configuration OK"},
+		{name: "check-config subcommand", args: []string{"serve", "-check-config", "-port", "9000"}, wantCode: 0, wantStdout: "configuration OK"},
+		{
+			name:       "check-config reports every problem",
+			args:       []string{"-check-config", "-port", "http"},
+			env:        map[string]string{"LOG_LEVEL": "verbose"},
+			wantCode:   1,
+			wantStderr: "invalid configuration:\n  - LOG_LEVEL: unknown level \"verbose\", use debug, info, warn or error\n  - PORT: invalid port \"http\"",
+		},
+		{
+			name:       "check-config reads the mock routes file",
+			args:       []string{"-check-config"},
+			env:        map[string]string{"MOCK_ROUTES_FILE": "missing-routes.yaml"},
+			wantCode:   1,
+			wantStderr: "invalid mock routes: open missing-routes.yaml",
+		},
+		{
+			name:       "log output errors",
+			env:        map[string]string{"LOG_OUTPUT": "/nonexistent/echo.log"},
+			wantCode:   1,
+			wantStderr: "Failed to open log output: open /nonexistent/echo.log",
+		},
+		{
+			name:       "config file errors",
+			args:       []string{"-config", "missing.toml"},
+			wantCode:   1,
+			wantStderr: "Failed to load configuration: open missing.toml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run() = %d, want %d (stderr %q)", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+// clearEnv unsets the configuration variables the tests depend on and restores them afterwards
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"CONFIG_FILE", "PORT", "ADMIN_PORT", "ADMIN_TOKEN", "LOG_LEVEL", "LOG_FORMAT", "LOG_OUTPUT", "LOG_SERVICE", "LOG_ENVIRONMENT", "LOG_INSTANCE_ID", "MOCK_ROUTES_FILE", "LISTEN_ADDR", "AUTH_ENABLED", "API_KEYS", "METRICS_TOKEN", "TLS_CERT_FILE", "TLS_KEY_FILE", "PROXY_PROTOCOL"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// logLevels are the named levels that signals step through, most verbose first
+var logLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// stepLogLevel moves level to the next named level, more verbose when verbose is true
+// The level stays put at either end, and levels between names such as "info+2" move to the nearest name
+func stepLogLevel(level *slog.LevelVar, verbose bool) slog.Level {
+	current := level.Level()
+	next := current
+	if verbose {
+		for _, l := range slices.Backward(logLevels) {
+			if l < current {
+				next = l
+				break
+			}
+		}
+	} else {
+		for _, l := range logLevels {
+			if l > current {
+				next = l
+				break
+			}
+		}
+	}
+	level.Set(next)
+	return next
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// watchLogLevelSignals is a no-op where SIGUSR1 and SIGUSR2 do not exist, use the admin endpoint instead
+func watchLogLevelSignals(context.Context, *slog.LevelVar, *slog.Logger) {}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestStepLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   slog.Level
+		verbose bool
+		want    slog.Level
+	}{
+		{name: "info to debug", start: slog.LevelInfo, verbose: true, want: slog.LevelDebug},
+		{name: "debug stays debug", start: slog.LevelDebug, verbose: true, want: slog.LevelDebug},
+		{name: "info to warn", start: slog.LevelInfo, verbose: false, want: slog.LevelWarn},
+		{name: "warn to error", start: slog.LevelWarn, verbose: false, want: slog.LevelError},
+		{name: "error stays error", start: slog.LevelError, verbose: false, want: slog.LevelError},
+		{name: "between names steps down to the nearest", start: slog.LevelInfo + 2, verbose: true, want: slog.LevelInfo},
+		{name: "between names steps up to the nearest", start: slog.LevelInfo + 2, verbose: false, want: slog.LevelWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			level.Set(tt.start)
+
+			if got := stepLogLevel(level, tt.verbose); got != tt.want {
+				t.Errorf("stepLogLevel() = %v, want %v", got, tt.want)
+			}
+			if level.Level() != tt.want {
+				t.Errorf("level = %v, want %v", level.Level(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lkendrickd/echo-server/internal/handlers"
+)
+
+// watchLogLevelSignals steps level on SIGUSR1 (more verbose) and SIGUSR2 (less verbose) until ctx is done
+func watchLogLevelSignals(ctx context.Context, level *slog.LevelVar, logger *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				previous := level.Level()
+				next := stepLogLevel(level, sig == syscall.SIGUSR1)
+				// Logged at warn or above so the change is not filtered out by the level it sets
+				logger.Log(ctx, max(slog.LevelWarn, next), "log level changed", "signal", sig.String(), "from", handlers.FormatLevel(previous), "to", handlers.FormatLevel(next))
+			}
+		}
+	}()
+}
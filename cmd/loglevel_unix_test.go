@@ -0,0 +1,68 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lkendrickd/echo-server/internal/handlers"
+)
+
+// syncBuffer is a bytes.Buffer safe for writes from the signal goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchLogLevelSignals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	level := new(slog.LevelVar)
+	var logs syncBuffer
+	watchLogLevelSignals(ctx, level, slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: level})))
+
+	// Every change must be logged, including the one that raises the level past the message's own
+	waitFor := func(want slog.Level) {
+		t.Helper()
+		line := `"to":"` + handlers.FormatLevel(want) + `"`
+		deadline := time.Now().Add(2 * time.Second)
+		for level.Level() != want || !strings.Contains(logs.String(), line) {
+			if time.Now().After(deadline) {
+				t.Fatalf("level = %v, want %v with %s logged, logs:\n%s", level.Level(), want, line, logs.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("Kill(SIGUSR1): %v", err)
+	}
+	waitFor(slog.LevelDebug)
+
+	for _, want := range []slog.Level{slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+			t.Fatalf("Kill(SIGUSR2): %v", err)
+		}
+		waitFor(want)
+	}
+}
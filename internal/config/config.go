@@ -1,42 +1,456 @@
 package config
 
 import (
+	"fmt"
+	"io/fs"
+	"net/netip"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
-// Config holds the application configuration loaded from environment variables
+// Default HTTP server limits, used when a setting is unset
+const (
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 15 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultShutdownTimeout   = 10 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20
+
+	DefaultHTTP2MaxConcurrentStreams = 250
+	DefaultHTTP2MaxReadFrameSize     = 1 << 20
+)
+
+// HTTP/2 frame size bounds from RFC 9113 section 4.2
+const (
+	minHTTP2FrameSize = 1 << 14
+	maxHTTP2FrameSize = 1<<24 - 1
+)
+
+// ListenerConfig describes an additional listener with its own routes and middleware
+// The config tags name its keys in the listeners list of a configuration file
+type ListenerConfig struct {
+	// Name identifies the listener in logs and selects its LISTENER_<NAME>_* variables
+	Name string `config:"name"`
+	// Addr is a TCP address, "unix:/path.sock" or "systemd[:name]"
+	Addr string `config:"addr"`
+	// Routes lists the mounted route groups: api, health, metrics and admin
+	Routes []string `config:"routes"`
+	// Middleware lists the optional middleware to apply, nil applies all of them
+	Middleware []string `config:"middleware"`
+	// TLSCertFile and TLSKeyFile serve TLS when both are set
+	TLSCertFile string `config:"tls_cert_file"`
+	TLSKeyFile  string `config:"tls_key_file"`
+	// ProxyProtocol requires a PROXY protocol header on every connection
+	ProxyProtocol bool `config:"proxy_protocol"`
+}
+
+// Config holds the application configuration loaded from defaults, an optional file and environment variables
 type Config struct {
 	Port        string
 	LogLevel    string
 	AuthEnabled bool
-	apiKeys     map[string]struct{}
-	mu          sync.RWMutex
+
+	// ListenAddr overrides Port with a full listen address such as "unix:/run/echo.sock" or "systemd"
+	ListenAddr string
+	// UnixSocketMode is the file mode applied to Unix domain sockets
+	UnixSocketMode fs.FileMode
+	// Listeners are served alongside the main and admin listeners
+	Listeners []ListenerConfig
+
+	// ProxyProtocol requires a PROXY protocol v1 or v2 header on every main listener connection
+	ProxyProtocol bool
+	// TrustedProxies lists the CIDRs allowed to send PROXY headers and forwarding headers
+	TrustedProxies []netip.Prefix
+
+	// MaxBodyBytes limits request bodies, 0 means unlimited
+	MaxBodyBytes int64
+	// RouteMaxBodyBytes overrides MaxBodyBytes per route pattern such as "POST /api/v1/echo"
+	RouteMaxBodyBytes map[string]int64
+	// RouteContentTypes lists the content types accepted per route pattern
+	RouteContentTypes map[string][]string
+
+	// CompressionEnabled compresses responses for clients that send Accept-Encoding
+	CompressionEnabled bool
+	// CompressionMinSize is the smallest response body in bytes that is compressed
+	CompressionMinSize int
+	// CompressionContentTypes lists the compressible response media types
+	CompressionContentTypes []string
+	// CompressionEncodings lists the enabled encodings in preference order
+	CompressionEncodings []string
+	// RequestDecompression decodes gzip and zstd request bodies on the echo endpoint
+	RequestDecompression bool
+
+	// CORSAllowedOrigins enables CORS for the listed origins, wildcards such as https://*.example.com are allowed
+	CORSAllowedOrigins []string
+	// CORSAllowedMethods lists the methods allowed on cross-origin requests
+	CORSAllowedMethods []string
+	// CORSAllowedHeaders lists the request headers allowed on cross-origin requests
+	CORSAllowedHeaders []string
+	// CORSExposedHeaders lists the response headers browsers may read
+	CORSExposedHeaders []string
+	// CORSAllowCredentials allows credentialed cross-origin requests
+	CORSAllowCredentials bool
+	// CORSMaxAge is how long in seconds browsers may cache preflight results
+	CORSMaxAge int
+
+	// RequestIDHeader is the header used to accept and return request IDs
+	RequestIDHeader string
+	// RequestIDFormat is the format of generated request IDs, uuidv7 or ulid
+	RequestIDFormat string
+
+	// MockRoutesFile names a YAML, TOML or JSON file of mock routes served on the main listener
+	MockRoutesFile string
+	// MockRoutesReloadInterval is how often the mock routes file is checked for changes, 0 never reloads
+	MockRoutesReloadInterval time.Duration
+
+	// LogFormat is json, logfmt or text, text is colored when written to a terminal
+	LogFormat string
+	// LogOutput is stdout, stderr or a file path
+	LogOutput string
+	// LogMaxSize rotates a LogOutput file once it reaches this many bytes, 0 never rotates
+	LogMaxSize int64
+	// LogMaxBackups is how many rotated log files are kept
+	LogMaxBackups int
+	// LogSource adds the source file and line to every log record
+	LogSource bool
+	// LogService, LogEnvironment and LogInstanceID are added to every log record when set
+	LogService     string
+	LogEnvironment string
+	LogInstanceID  string
+
+	// AccessLogEnabled logs one record per request
+	AccessLogEnabled bool
+	// AccessLogSampleRate is the fraction of successful requests logged, 5xx responses are always logged
+	AccessLogSampleRate float64
+	// AccessLogFields restricts json access log records to the listed fields
+	AccessLogFields []string
+	// AccessLogFormat is json, common or combined
+	AccessLogFormat string
+	// AccessLogFile receives common and combined log lines, stdout if empty
+	AccessLogFile string
+
+	// ReadTimeout limits reading an entire request including the body
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout limits reading request headers, it never exceeds ReadTimeout
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout limits writing the response, long-running streams need a larger value
+	WriteTimeout time.Duration
+	// IdleTimeout limits how long keep-alive connections wait for the next request
+	IdleTimeout time.Duration
+	// MaxHeaderBytes limits the size of request headers
+	MaxHeaderBytes int
+	// TLSCertFile and TLSKeyFile serve TLS on the main listener when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// HTTP3Enabled serves HTTP/3 over QUIC alongside the TLS main listener
+	HTTP3Enabled bool
+	// HTTP3Addr is the UDP address for HTTP/3, the main listen address if empty
+	HTTP3Addr string
+
+	// H2CEnabled serves HTTP/2 cleartext, by prior knowledge or Upgrade, on plaintext listeners
+	H2CEnabled bool
+	// HTTP2MaxConcurrentStreams limits concurrent streams per HTTP/2 connection
+	HTTP2MaxConcurrentStreams int
+	// HTTP2MaxReadFrameSize is the largest HTTP/2 frame the server reads, between 16KiB and 16MiB
+	HTTP2MaxReadFrameSize int
+
+	// ShutdownTimeout is how long shutdown waits for in-flight requests to finish
+	ShutdownTimeout time.Duration
+
+	// ShutdownDrainDelay is how long readiness fails before connections are shut down
+	ShutdownDrainDelay time.Duration
+
+	// AdminPort moves operational endpoints such as /metrics to a separate listener when set
+	AdminPort string
+	// AdminToken is a bearer token required by the /admin/ endpoints
+	AdminToken string
+
+	// MetricsDisabled turns off the /metrics endpoint, the zero value keeps it enabled
+	MetricsDisabled bool
+	// MetricsToken is a bearer token required to scrape /metrics
+	MetricsToken string
+	// MetricsUsername and MetricsPassword enable basic auth on /metrics
+	MetricsUsername string
+	MetricsPassword string
+
+	// MetricsBuckets overrides the request duration histogram buckets in seconds
+	MetricsBuckets []float64
+	// MetricsNativeHistograms enables Prometheus native (sparse) histograms
+	MetricsNativeHistograms bool
+	// MetricsNativeBucketFactor controls native histogram resolution, must be greater than 1
+	MetricsNativeBucketFactor float64
+	// MetricsNativeMaxBuckets caps the number of native histogram buckets
+	MetricsNativeMaxBuckets uint32
+
+	// envProblems holds malformed environment variables found while loading, reported by Validate
+	envProblems []string
+	// sources maps the environment variable name of each setting not left at its default to the layer that set it
+	sources map[string]string
+
+	apiKeys map[string]struct{}
+	mu      sync.RWMutex
 }
 
-// New creates a new Config from environment variables
+// New creates a new Config from defaults and environment variables
 func New() *Config {
-	cfg := &Config{
-		Port:        getEnv("PORT", "8080"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
-		AuthEnabled: getEnvBool("AUTH_ENABLED", false),
-		apiKeys:     make(map[string]struct{}),
-	}
-
-	// Parse API keys from comma-separated list
-	keysStr := getEnv("API_KEYS", "")
-	if keysStr != "" {
-		keys := strings.Split(keysStr, ",")
-		for _, key := range keys {
-			trimmed := strings.TrimSpace(key)
-			if trimmed != "" {
-				cfg.apiKeys[trimmed] = struct{}{}
-			}
+	cfg := defaults()
+	cfg.loadEnv()
+	cfg.normalize()
+	return cfg
+}
+
+// Load creates a Config from defaults, the configuration file at path and environment variables,
+// each layer overriding the previous one. An empty path falls back to CONFIG_FILE
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = getEnv("CONFIG_FILE", "")
+	}
+
+	cfg := defaults()
+	if path != "" {
+		fc, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := fc.apply(cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, key := range fc.keys() {
+			cfg.SetSource(key, SourceFile)
 		}
 	}
+	cfg.loadEnv()
+	cfg.normalize()
+	return cfg, nil
+}
 
-	return cfg
+// defaults returns the configuration used when neither a file nor the environment sets a value
+func defaults() *Config {
+	return &Config{
+		Port:     "8080",
+		LogLevel: "info",
+
+		MockRoutesReloadInterval: 2 * time.Second,
+
+		LogFormat:     "json",
+		LogOutput:     "stdout",
+		LogMaxSize:    100 << 20,
+		LogMaxBackups: 5,
+
+		UnixSocketMode: 0o660,
+
+		ReadTimeout:       DefaultReadTimeout,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		WriteTimeout:      DefaultWriteTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		ShutdownTimeout:   DefaultShutdownTimeout,
+
+		HTTP2MaxConcurrentStreams: DefaultHTTP2MaxConcurrentStreams,
+		HTTP2MaxReadFrameSize:     DefaultHTTP2MaxReadFrameSize,
+
+		MaxBodyBytes: 1 << 20,
+
+		CompressionEnabled: true,
+		CompressionMinSize: 1024,
+		CompressionContentTypes: []string{
+			"application/json", "application/javascript", "application/xml", "image/svg+xml", "text/*",
+		},
+		CompressionEncodings: []string{"zstd", "br", "gzip"},
+		RequestDecompression: true,
+
+		CORSAllowedMethods: []string{"GET", "POST", "HEAD"},
+		CORSAllowedHeaders: []string{"Content-Type", "X-API-Key", "X-Request-ID"},
+		CORSExposedHeaders: []string{"X-Request-ID"},
+		CORSMaxAge:         600,
+
+		RequestIDHeader: "X-Request-ID",
+		RequestIDFormat: "uuidv7",
+
+		AccessLogSampleRate: 1,
+		AccessLogFormat:     "json",
+
+		MetricsNativeBucketFactor: 1.1,
+		MetricsNativeMaxBuckets:   160,
+
+		apiKeys: make(map[string]struct{}),
+	}
+}
+
+// loadEnv overrides c with the environment variables that are set
+func (c *Config) loadEnv() {
+	c.checkEnv()
+
+	c.Port = getEnv("PORT", c.Port)
+	c.LogLevel = getEnv("LOG_LEVEL", c.LogLevel)
+	c.AuthEnabled = getEnvBool("AUTH_ENABLED", c.AuthEnabled)
+	c.AdminPort = getEnv("ADMIN_PORT", c.AdminPort)
+	c.AdminToken = c.getEnvSecret("ADMIN_TOKEN", c.AdminToken)
+
+	c.ListenAddr = getEnv("LISTEN_ADDR", c.ListenAddr)
+	c.UnixSocketMode = getEnvFileMode("UNIX_SOCKET_MODE", c.UnixSocketMode)
+	c.Listeners = getEnvListeners("LISTENERS", c.Listeners)
+	c.ProxyProtocol = getEnvBool("PROXY_PROTOCOL", c.ProxyProtocol)
+	c.TrustedProxies = getEnvPrefixes("TRUSTED_PROXIES", c.TrustedProxies)
+
+	c.ReadTimeout = getEnvPositiveDuration("READ_TIMEOUT", c.ReadTimeout)
+	c.ReadHeaderTimeout = getEnvPositiveDuration("READ_HEADER_TIMEOUT", c.ReadHeaderTimeout)
+	c.WriteTimeout = getEnvPositiveDuration("WRITE_TIMEOUT", c.WriteTimeout)
+	c.IdleTimeout = getEnvPositiveDuration("IDLE_TIMEOUT", c.IdleTimeout)
+	c.MaxHeaderBytes = getEnvInt("MAX_HEADER_BYTES", c.MaxHeaderBytes)
+	c.ShutdownTimeout = getEnvPositiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	c.TLSCertFile = getEnv("TLS_CERT_FILE", c.TLSCertFile)
+	c.TLSKeyFile = getEnv("TLS_KEY_FILE", c.TLSKeyFile)
+	c.HTTP3Enabled = getEnvBool("HTTP3_ENABLED", c.HTTP3Enabled)
+	c.HTTP3Addr = getEnv("HTTP3_ADDR", c.HTTP3Addr)
+
+	c.H2CEnabled = getEnvBool("H2C_ENABLED", c.H2CEnabled)
+	c.HTTP2MaxConcurrentStreams = getEnvInt("HTTP2_MAX_CONCURRENT_STREAMS", c.HTTP2MaxConcurrentStreams)
+	c.HTTP2MaxReadFrameSize = getEnvInt("HTTP2_MAX_READ_FRAME_SIZE", c.HTTP2MaxReadFrameSize)
+	c.ShutdownDrainDelay = getEnvDuration("SHUTDOWN_DRAIN_DELAY", c.ShutdownDrainDelay)
+
+	c.MaxBodyBytes = int64(getEnvInt("MAX_BODY_BYTES", int(c.MaxBodyBytes)))
+	c.RouteMaxBodyBytes = getEnvIntMap("ROUTE_MAX_BODY_BYTES", c.RouteMaxBodyBytes)
+	c.RouteContentTypes = getEnvListMap("ROUTE_CONTENT_TYPES", c.RouteContentTypes)
+
+	c.CompressionEnabled = getEnvBool("COMPRESSION_ENABLED", c.CompressionEnabled)
+	c.CompressionMinSize = getEnvInt("COMPRESSION_MIN_SIZE", c.CompressionMinSize)
+	c.CompressionContentTypes = getEnvList("COMPRESSION_CONTENT_TYPES", c.CompressionContentTypes)
+	c.CompressionEncodings = getEnvList("COMPRESSION_ENCODINGS", c.CompressionEncodings)
+	c.RequestDecompression = getEnvBool("REQUEST_DECOMPRESSION", c.RequestDecompression)
+
+	c.CORSAllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins)
+	c.CORSAllowedMethods = getEnvList("CORS_ALLOWED_METHODS", c.CORSAllowedMethods)
+	c.CORSAllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", c.CORSAllowedHeaders)
+	c.CORSExposedHeaders = getEnvList("CORS_EXPOSED_HEADERS", c.CORSExposedHeaders)
+	c.CORSAllowCredentials = getEnvBool("CORS_ALLOW_CREDENTIALS", c.CORSAllowCredentials)
+	c.CORSMaxAge = getEnvInt("CORS_MAX_AGE", c.CORSMaxAge)
+
+	c.RequestIDHeader = getEnv("REQUEST_ID_HEADER", c.RequestIDHeader)
+	c.RequestIDFormat = getEnv("REQUEST_ID_FORMAT", c.RequestIDFormat)
+
+	c.MockRoutesFile = getEnv("MOCK_ROUTES_FILE", c.MockRoutesFile)
+	c.MockRoutesReloadInterval = getEnvDuration("MOCK_ROUTES_RELOAD_INTERVAL", c.MockRoutesReloadInterval)
+
+	c.LogFormat = getEnv("LOG_FORMAT", c.LogFormat)
+	c.LogOutput = getEnv("LOG_OUTPUT", c.LogOutput)
+	c.LogMaxSize = int64(getEnvInt("LOG_MAX_SIZE", int(c.LogMaxSize)))
+	c.LogMaxBackups = getEnvInt("LOG_MAX_BACKUPS", c.LogMaxBackups)
+	c.LogSource = getEnvBool("LOG_SOURCE", c.LogSource)
+	c.LogService = getEnv("LOG_SERVICE", c.LogService)
+	c.LogEnvironment = getEnv("LOG_ENVIRONMENT", c.LogEnvironment)
+	c.LogInstanceID = getEnv("LOG_INSTANCE_ID", c.LogInstanceID)
+
+	c.AccessLogEnabled = getEnvBool("ACCESS_LOG_ENABLED", c.AccessLogEnabled)
+	c.AccessLogSampleRate = getEnvFloat("ACCESS_LOG_SAMPLE_RATE", c.AccessLogSampleRate)
+	c.AccessLogFields = getEnvList("ACCESS_LOG_FIELDS", c.AccessLogFields)
+	c.AccessLogFormat = getEnv("ACCESS_LOG_FORMAT", c.AccessLogFormat)
+	c.AccessLogFile = getEnv("ACCESS_LOG_FILE", c.AccessLogFile)
+
+	c.MetricsDisabled = !getEnvBool("METRICS_ENABLED", !c.MetricsDisabled)
+	c.MetricsToken = c.getEnvSecret("METRICS_TOKEN", c.MetricsToken)
+	c.MetricsUsername = getEnv("METRICS_USERNAME", c.MetricsUsername)
+	c.MetricsPassword = c.getEnvSecret("METRICS_PASSWORD", c.MetricsPassword)
+	c.MetricsBuckets = getEnvFloats("METRICS_BUCKETS", c.MetricsBuckets)
+	c.MetricsNativeHistograms = getEnvBool("METRICS_NATIVE_HISTOGRAMS", c.MetricsNativeHistograms)
+	c.MetricsNativeBucketFactor = getEnvFloat("METRICS_NATIVE_BUCKET_FACTOR", c.MetricsNativeBucketFactor)
+	c.MetricsNativeMaxBuckets = uint32(getEnvInt("METRICS_NATIVE_MAX_BUCKETS", int(c.MetricsNativeMaxBuckets)))
+
+	// API_KEYS and API_KEYS_FILE replace keys from the configuration file rather than adding to them
+	if keys, ok := c.getEnvSecretFile("API_KEYS"); ok {
+		c.setAPIKeys(keys)
+	} else if keys, exists := os.LookupEnv("API_KEYS"); exists {
+		c.setAPIKeys(strings.Split(keys, ","))
+	}
+	c.markEnvSources()
+}
+
+// getEnvSecret retrieves a secret from the file named by key+"_FILE" or from key itself
+// A secret file must hold exactly one value, problems are recorded for Validate and keep defaultValue
+func (c *Config) getEnvSecret(key, defaultValue string) string {
+	lines, ok := c.getEnvSecretFile(key)
+	if !ok {
+		return getEnv(key, defaultValue)
+	}
+	if len(lines) != 1 {
+		c.envProblems = append(c.envProblems, fmt.Sprintf("%s_FILE: expected one value, found %d", key, len(lines)))
+		return defaultValue
+	}
+	return lines[0]
+}
+
+// getEnvSecretFile reads the secret file named by key+"_FILE", reporting false if it is unset or unreadable
+// Setting both key and key+"_FILE" is recorded as a problem because neither can be preferred safely
+func (c *Config) getEnvSecretFile(key string) ([]string, bool) {
+	path, exists := os.LookupEnv(key + "_FILE")
+	if !exists {
+		return nil, false
+	}
+	if _, both := os.LookupEnv(key); both {
+		c.envProblems = append(c.envProblems, fmt.Sprintf("%s, %s_FILE: set only one of them", key, key))
+		return nil, false
+	}
+
+	lines, err := readSecretFile(path)
+	if err != nil {
+		c.envProblems = append(c.envProblems, fmt.Sprintf("%s_FILE: %v", key, err))
+		return nil, false
+	}
+	return lines, true
+}
+
+// readSecretFile reads the values in a mounted secret file, one per line
+// Surrounding whitespace is trimmed, and blank lines and lines starting with # are skipped
+func readSecretFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// normalize derives settings that depend on each other, out-of-range values are left for Validate to report
+func (c *Config) normalize() {
+	// Headers are part of the request, so their deadline cannot be later than the whole read
+	if c.ReadHeaderTimeout > c.ReadTimeout {
+		c.ReadHeaderTimeout = c.ReadTimeout
+	}
+}
+
+// setAPIKeys replaces the configured API keys, ignoring blank entries
+func (c *Config) setAPIKeys(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.apiKeys = make(map[string]struct{})
+	for _, key := range keys {
+		if trimmed := strings.TrimSpace(key); trimmed != "" {
+			c.apiKeys[trimmed] = struct{}{}
+		}
+	}
+}
+
+// Address returns the main listen address, ListenAddr if set or ":Port" otherwise
+func (c *Config) Address() string {
+	if c.ListenAddr != "" {
+		return c.ListenAddr
+	}
+	return ":" + c.Port
 }
 
 // ValidateAPIKey checks if the provided key is valid using constant-time comparison
@@ -56,6 +470,19 @@ func (c *Config) APIKeyCount() int {
 	return len(c.apiKeys)
 }
 
+// APIKeyIDs returns the sorted IDs of the configured API keys, computed by id so the keys themselves are never exposed
+func (c *Config) APIKeyIDs(id func(key string) string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, 0, len(c.apiKeys))
+	for key := range c.apiKeys {
+		ids = append(ids, id(key))
+	}
+	slices.Sort(ids)
+	return ids
+}
+
 // HasAPIKeys returns true if any API keys are configured
 func (c *Config) HasAPIKeys() bool {
 	return c.APIKeyCount() > 0
@@ -76,12 +503,320 @@ func getEnvBool(key string, defaultValue bool) bool {
 		return defaultValue
 	}
 
+	b, err := parseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// parseBool accepts true, 1, yes and on or false, 0, no and off in any case
+func parseBool(value string) (bool, error) {
 	switch strings.ToLower(value) {
 	case "true", "1", "yes", "on":
-		return true
+		return true, nil
 	case "false", "0", "no", "off":
-		return false
+		return false, nil
 	default:
+		return false, fmt.Errorf("invalid boolean %q, use true or false", value)
+	}
+}
+
+// getEnvDuration retrieves an environment variable as a time.Duration such as "5s"
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
 		return defaultValue
 	}
+
+	d, err := parseDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// parseDuration parses a non-negative duration such as "5s"
+func parseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q, use a value such as 500ms, 15s or 5m", value)
+	}
+	return d, nil
+}
+
+// parsePositiveDuration parses a duration greater than zero
+func parsePositiveDuration(value string) (time.Duration, error) {
+	d, err := parseDuration(value)
+	if err == nil && d == 0 {
+		err = fmt.Errorf("duration %q must be greater than zero", value)
+	}
+	return d, err
+}
+
+// getEnvPositiveDuration retrieves an environment variable as a duration greater than zero
+func getEnvPositiveDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	d, err := parsePositiveDuration(value)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvFileMode retrieves an environment variable as an octal file mode such as "0660"
+func getEnvFileMode(key string, defaultValue fs.FileMode) fs.FileMode {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	mode, err := parseFileMode(value)
+	if err != nil {
+		return defaultValue
+	}
+	return mode
+}
+
+// parseFileMode parses an octal file mode between 0001 and 0777
+func parseFileMode(value string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil || mode == 0 || mode > 0o777 {
+		return 0, fmt.Errorf("invalid file mode %q, use octal such as 0660", value)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// getEnvListeners reads the listener names in key and their LISTENER_<NAME>_* settings
+// Listeners without an address or with a duplicate name are skipped
+func getEnvListeners(key string, defaultValue []ListenerConfig) []ListenerConfig {
+	if _, exists := os.LookupEnv(key); !exists {
+		return defaultValue
+	}
+
+	var listeners []ListenerConfig
+	seen := make(map[string]bool)
+	for _, name := range getEnvList(key, nil) {
+		prefix := "LISTENER_" + envName(name) + "_"
+		addr := strings.TrimSpace(getEnv(prefix+"ADDR", ""))
+		if addr == "" || seen[name] || name == "main" || name == "admin" {
+			continue
+		}
+		seen[name] = true
+
+		listeners = append(listeners, ListenerConfig{
+			Name:          name,
+			Addr:          addr,
+			Routes:        getEnvList(prefix+"ROUTES", nil),
+			Middleware:    getEnvList(prefix+"MIDDLEWARE", nil),
+			TLSCertFile:   getEnv(prefix+"TLS_CERT", ""),
+			TLSKeyFile:    getEnv(prefix+"TLS_KEY", ""),
+			ProxyProtocol: getEnvBool(prefix+"PROXY_PROTOCOL", false),
+		})
+	}
+	return listeners
+}
+
+// getEnvPrefixes retrieves an environment variable as a list of CIDRs, bare IPs become single-address prefixes
+// Invalid entries are skipped
+func getEnvPrefixes(key string, defaultValue []netip.Prefix) []netip.Prefix {
+	if _, exists := os.LookupEnv(key); !exists {
+		return defaultValue
+	}
+
+	var prefixes []netip.Prefix
+	for _, item := range getEnvList(key, nil) {
+		if p, err := netip.ParsePrefix(item); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// parsePrefixes parses a list of CIDRs and bare IPs, failing on the first invalid entry
+func parsePrefixes(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		if p, err := netip.ParsePrefix(item); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR or IP %q", item)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// envName converts name to its environment variable form, "tls-public" becomes "TLS_PUBLIC"
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// getEnvList retrieves an environment variable as a comma-separated list of non-empty strings
+func getEnvList(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	return splitList(value)
+}
+
+// getEnvMap retrieves an environment variable as comma-separated key=value pairs
+// Keys may contain spaces, so route patterns such as "POST /api/v1/echo" work as keys
+func getEnvMap(key string) map[string]string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return nil
+	}
+
+	m := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		m[k] = strings.TrimSpace(v)
+	}
+	return m
+}
+
+// getEnvIntMap retrieves an environment variable as key=integer pairs, skipping invalid entries
+func getEnvIntMap(key string, defaultValue map[string]int64) map[string]int64 {
+	if _, exists := os.LookupEnv(key); !exists {
+		return defaultValue
+	}
+
+	var m map[string]int64
+	for k, v := range getEnvMap(key) {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]int64)
+		}
+		m[k] = n
+	}
+	return m
+}
+
+// getEnvListMap retrieves an environment variable as key=a|b|c pairs
+func getEnvListMap(key string, defaultValue map[string][]string) map[string][]string {
+	if _, exists := os.LookupEnv(key); !exists {
+		return defaultValue
+	}
+
+	var m map[string][]string
+	for k, v := range getEnvMap(key) {
+		var list []string
+		for _, item := range strings.Split(v, "|") {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				list = append(list, trimmed)
+			}
+		}
+		if m == nil {
+			m = make(map[string][]string)
+		}
+		m[k] = list
+	}
+	return m
+}
+
+// getEnvInt retrieves an environment variable as a non-negative integer
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	n, err := parseInt(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+// parseInt parses a non-negative integer
+func parseInt(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid value %q, use a non-negative integer", value)
+	}
+	return n, nil
+}
+
+// getEnvFloat retrieves an environment variable as a float
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	f, err := parseFloat(value)
+	if err != nil {
+		return defaultValue
+	}
+	return f
+}
+
+// parseFloat parses a number such as 0.5
+func parseFloat(value string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return f, nil
+}
+
+// getEnvFloats retrieves an environment variable as a comma-separated list of floats
+// The whole list falls back to the default if any entry is invalid
+func getEnvFloats(key string, defaultValue []float64) []float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists || strings.TrimSpace(value) == "" {
+		return defaultValue
+	}
+
+	floats, err := parseFloats(value)
+	if err != nil {
+		return defaultValue
+	}
+	return floats
+}
+
+// parseFloats parses a comma-separated list of numbers, skipping empty entries
+func parseFloats(value string) ([]float64, error) {
+	var floats []float64
+	for _, part := range strings.Split(value, ",") {
+		trimmed := strings.TrimSpace(part)
+		if trimmed == "" {
+			continue
+		}
+		f, err := parseFloat(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		floats = append(floats, f)
+	}
+	return floats, nil
 }
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"errors"
+	"maps"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -261,11 +267,728 @@ func TestGetEnvBool_NotSet(t *testing.T) {
 	}
 }
 
+func TestNew_MetricsSettings(t *testing.T) {
+	tests := []struct {
+		name             string
+		envVars          map[string]string
+		wantBuckets      []float64
+		wantNative       bool
+		wantBucketFactor float64
+		wantMaxBuckets   uint32
+	}{
+		{
+			name:             "defaults",
+			envVars:          map[string]string{},
+			wantBuckets:      nil,
+			wantNative:       false,
+			wantBucketFactor: 1.1,
+			wantMaxBuckets:   160,
+		},
+		{
+			name: "custom buckets and native histograms",
+			envVars: map[string]string{
+				"METRICS_BUCKETS":              "0.0001, 0.0005,0.001",
+				"METRICS_NATIVE_HISTOGRAMS":    "true",
+				"METRICS_NATIVE_BUCKET_FACTOR": "1.05",
+				"METRICS_NATIVE_MAX_BUCKETS":   "100",
+			},
+			wantBuckets:      []float64{0.0001, 0.0005, 0.001},
+			wantNative:       true,
+			wantBucketFactor: 1.05,
+			wantMaxBuckets:   100,
+		},
+		{
+			name: "invalid values fall back to defaults",
+			envVars: map[string]string{
+				"METRICS_BUCKETS":              "0.1,fast",
+				"METRICS_NATIVE_BUCKET_FACTOR": "big",
+				"METRICS_NATIVE_MAX_BUCKETS":   "-1",
+			},
+			wantBuckets:      nil,
+			wantNative:       false,
+			wantBucketFactor: 1.1,
+			wantMaxBuckets:   160,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.envVars {
+				t.Setenv(k, v)
+			}
+
+			cfg := New()
+
+			if !slices.Equal(cfg.MetricsBuckets, tt.wantBuckets) {
+				t.Errorf("MetricsBuckets = %v, want %v", cfg.MetricsBuckets, tt.wantBuckets)
+			}
+
+			if cfg.MetricsNativeHistograms != tt.wantNative {
+				t.Errorf("MetricsNativeHistograms = %v, want %v", cfg.MetricsNativeHistograms, tt.wantNative)
+			}
+
+			if cfg.MetricsNativeBucketFactor != tt.wantBucketFactor {
+				t.Errorf("MetricsNativeBucketFactor = %v, want %v", cfg.MetricsNativeBucketFactor, tt.wantBucketFactor)
+			}
+
+			if cfg.MetricsNativeMaxBuckets != tt.wantMaxBuckets {
+				t.Errorf("MetricsNativeMaxBuckets = %d, want %d", cfg.MetricsNativeMaxBuckets, tt.wantMaxBuckets)
+			}
+		})
+	}
+}
+
+func TestNew_OperationalSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.MetricsDisabled {
+		t.Error("MetricsDisabled = true by default, want false")
+	}
+	if cfg.AdminPort != "" {
+		t.Errorf("AdminPort = %q by default, want empty", cfg.AdminPort)
+	}
+
+	t.Setenv("ADMIN_PORT", "9090")
+	t.Setenv("ADMIN_TOKEN", "operator")
+	t.Setenv("METRICS_ENABLED", "false")
+	t.Setenv("METRICS_TOKEN", "scrape")
+	t.Setenv("METRICS_USERNAME", "prom")
+	t.Setenv("METRICS_PASSWORD", "secret")
+
+	cfg = New()
+	if cfg.AdminPort != "9090" {
+		t.Errorf("AdminPort = %q, want %q", cfg.AdminPort, "9090")
+	}
+	if cfg.AdminToken != "operator" {
+		t.Errorf("AdminToken = %q, want %q", cfg.AdminToken, "operator")
+	}
+	if !cfg.MetricsDisabled {
+		t.Error("MetricsDisabled = false, want true")
+	}
+	if cfg.MetricsToken != "scrape" || cfg.MetricsUsername != "prom" || cfg.MetricsPassword != "secret" {
+		t.Errorf("metrics credentials = %q/%q/%q, want scrape/prom/secret", cfg.MetricsToken, cfg.MetricsUsername, cfg.MetricsPassword)
+	}
+}
+
+func TestNew_LogSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.LogFormat != "json" || cfg.LogOutput != "stdout" || cfg.LogSource {
+		t.Errorf("log format/output/source = %q/%q/%v by default, want json/stdout/false", cfg.LogFormat, cfg.LogOutput, cfg.LogSource)
+	}
+	if cfg.LogMaxSize != 100<<20 || cfg.LogMaxBackups != 5 {
+		t.Errorf("log rotation = %d bytes/%d backups by default, want %d/5", cfg.LogMaxSize, cfg.LogMaxBackups, 100<<20)
+	}
+
+	t.Setenv("LOG_FORMAT", "text")
+	t.Setenv("LOG_OUTPUT", "/var/log/echo.log")
+	t.Setenv("LOG_MAX_SIZE", "1048576")
+	t.Setenv("LOG_MAX_BACKUPS", "0")
+	t.Setenv("LOG_SOURCE", "true")
+	t.Setenv("LOG_SERVICE", "echo")
+	t.Setenv("LOG_ENVIRONMENT", "staging")
+	t.Setenv("LOG_INSTANCE_ID", "echo-7f9c")
+
+	cfg = New()
+	if cfg.LogFormat != "text" || cfg.LogOutput != "/var/log/echo.log" || !cfg.LogSource {
+		t.Errorf("log format/output/source = %q/%q/%v, want text//var/log/echo.log/true", cfg.LogFormat, cfg.LogOutput, cfg.LogSource)
+	}
+	if cfg.LogMaxSize != 1<<20 || cfg.LogMaxBackups != 0 {
+		t.Errorf("log rotation = %d bytes/%d backups, want %d/0", cfg.LogMaxSize, cfg.LogMaxBackups, 1<<20)
+	}
+	if cfg.LogService != "echo" || cfg.LogEnvironment != "staging" || cfg.LogInstanceID != "echo-7f9c" {
+		t.Errorf("log attributes = %q/%q/%q, want echo/staging/echo-7f9c", cfg.LogService, cfg.LogEnvironment, cfg.LogInstanceID)
+	}
+}
+
+func TestNew_MockRoutes(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.MockRoutesFile != "" || cfg.MockRoutesReloadInterval != 2*time.Second {
+		t.Errorf("mock routes = %q every %v by default, want none every 2s", cfg.MockRoutesFile, cfg.MockRoutesReloadInterval)
+	}
+
+	t.Setenv("MOCK_ROUTES_FILE", "/etc/echo/routes.yaml")
+	t.Setenv("MOCK_ROUTES_RELOAD_INTERVAL", "0s")
+
+	cfg = New()
+	if cfg.MockRoutesFile != "/etc/echo/routes.yaml" || cfg.MockRoutesReloadInterval != 0 {
+		t.Errorf("mock routes = %q every %v, want /etc/echo/routes.yaml every 0s", cfg.MockRoutesFile, cfg.MockRoutesReloadInterval)
+	}
+}
+
+func TestNew_ServerLimits(t *testing.T) {
+	tests := []struct {
+		name                  string
+		env                   map[string]string
+		wantReadTimeout       time.Duration
+		wantReadHeaderTimeout time.Duration
+		wantWriteTimeout      time.Duration
+		wantIdleTimeout       time.Duration
+		wantMaxHeaderBytes    int
+		wantShutdownTimeout   time.Duration
+	}{
+		{
+			name:                  "defaults",
+			wantReadTimeout:       DefaultReadTimeout,
+			wantReadHeaderTimeout: DefaultReadHeaderTimeout,
+			wantWriteTimeout:      DefaultWriteTimeout,
+			wantIdleTimeout:       DefaultIdleTimeout,
+			wantMaxHeaderBytes:    DefaultMaxHeaderBytes,
+			wantShutdownTimeout:   DefaultShutdownTimeout,
+		},
+		{
+			name: "custom values",
+			env: map[string]string{
+				"READ_TIMEOUT":        "5m",
+				"READ_HEADER_TIMEOUT": "2s",
+				"WRITE_TIMEOUT":       "10m",
+				"IDLE_TIMEOUT":        "30s",
+				"MAX_HEADER_BYTES":    "8192",
+				"SHUTDOWN_TIMEOUT":    "1m",
+			},
+			wantReadTimeout:       5 * time.Minute,
+			wantReadHeaderTimeout: 2 * time.Second,
+			wantWriteTimeout:      10 * time.Minute,
+			wantIdleTimeout:       30 * time.Second,
+			wantMaxHeaderBytes:    8192,
+			wantShutdownTimeout:   time.Minute,
+		},
+		{
+			name: "invalid and zero values fall back to defaults",
+			env: map[string]string{
+				"READ_TIMEOUT":     "fast",
+				"WRITE_TIMEOUT":    "0s",
+				"IDLE_TIMEOUT":     "-1s",
+				"SHUTDOWN_TIMEOUT": "0",
+			},
+			wantReadTimeout:       DefaultReadTimeout,
+			wantReadHeaderTimeout: DefaultReadHeaderTimeout,
+			wantWriteTimeout:      DefaultWriteTimeout,
+			wantIdleTimeout:       DefaultIdleTimeout,
+			wantMaxHeaderBytes:    DefaultMaxHeaderBytes,
+			wantShutdownTimeout:   DefaultShutdownTimeout,
+		},
+		{
+			name:                  "zero header limit kept for Validate",
+			env:                   map[string]string{"MAX_HEADER_BYTES": "0"},
+			wantReadTimeout:       DefaultReadTimeout,
+			wantReadHeaderTimeout: DefaultReadHeaderTimeout,
+			wantWriteTimeout:      DefaultWriteTimeout,
+			wantIdleTimeout:       DefaultIdleTimeout,
+			wantMaxHeaderBytes:    0,
+			wantShutdownTimeout:   DefaultShutdownTimeout,
+		},
+		{
+			name: "header timeout capped at read timeout",
+			env: map[string]string{
+				"READ_TIMEOUT":        "1s",
+				"READ_HEADER_TIMEOUT": "10s",
+			},
+			wantReadTimeout:       time.Second,
+			wantReadHeaderTimeout: time.Second,
+			wantWriteTimeout:      DefaultWriteTimeout,
+			wantIdleTimeout:       DefaultIdleTimeout,
+			wantMaxHeaderBytes:    DefaultMaxHeaderBytes,
+			wantShutdownTimeout:   DefaultShutdownTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := New()
+
+			if cfg.ReadTimeout != tt.wantReadTimeout {
+				t.Errorf("ReadTimeout = %v, want %v", cfg.ReadTimeout, tt.wantReadTimeout)
+			}
+			if cfg.ReadHeaderTimeout != tt.wantReadHeaderTimeout {
+				t.Errorf("ReadHeaderTimeout = %v, want %v", cfg.ReadHeaderTimeout, tt.wantReadHeaderTimeout)
+			}
+			if cfg.WriteTimeout != tt.wantWriteTimeout {
+				t.Errorf("WriteTimeout = %v, want %v", cfg.WriteTimeout, tt.wantWriteTimeout)
+			}
+			if cfg.IdleTimeout != tt.wantIdleTimeout {
+				t.Errorf("IdleTimeout = %v, want %v", cfg.IdleTimeout, tt.wantIdleTimeout)
+			}
+			if cfg.MaxHeaderBytes != tt.wantMaxHeaderBytes {
+				t.Errorf("MaxHeaderBytes = %d, want %d", cfg.MaxHeaderBytes, tt.wantMaxHeaderBytes)
+			}
+			if cfg.ShutdownTimeout != tt.wantShutdownTimeout {
+				t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, tt.wantShutdownTimeout)
+			}
+		})
+	}
+}
+
+func TestNew_HTTP3Settings(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantCert  string
+		wantKey   string
+		wantHTTP3 bool
+		wantAddr  string
+	}{
+		{name: "defaults"},
+		{
+			name: "enabled with TLS",
+			env: map[string]string{
+				"TLS_CERT_FILE": "/etc/echo/tls.crt",
+				"TLS_KEY_FILE":  "/etc/echo/tls.key",
+				"HTTP3_ENABLED": "true",
+				"HTTP3_ADDR":    ":8443",
+			},
+			wantCert:  "/etc/echo/tls.crt",
+			wantKey:   "/etc/echo/tls.key",
+			wantHTTP3: true,
+			wantAddr:  ":8443",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := New()
+
+			if cfg.TLSCertFile != tt.wantCert {
+				t.Errorf("TLSCertFile = %q, want %q", cfg.TLSCertFile, tt.wantCert)
+			}
+			if cfg.TLSKeyFile != tt.wantKey {
+				t.Errorf("TLSKeyFile = %q, want %q", cfg.TLSKeyFile, tt.wantKey)
+			}
+			if cfg.HTTP3Enabled != tt.wantHTTP3 {
+				t.Errorf("HTTP3Enabled = %v, want %v", cfg.HTTP3Enabled, tt.wantHTTP3)
+			}
+			if cfg.HTTP3Addr != tt.wantAddr {
+				t.Errorf("HTTP3Addr = %q, want %q", cfg.HTTP3Addr, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestNew_HTTP2Settings(t *testing.T) {
+	tests := []struct {
+		name             string
+		env              map[string]string
+		wantH2C          bool
+		wantMaxStreams   int
+		wantMaxFrameSize int
+	}{
+		{
+			name:             "defaults",
+			wantMaxStreams:   DefaultHTTP2MaxConcurrentStreams,
+			wantMaxFrameSize: DefaultHTTP2MaxReadFrameSize,
+		},
+		{
+			name: "custom values",
+			env: map[string]string{
+				"H2C_ENABLED":                  "true",
+				"HTTP2_MAX_CONCURRENT_STREAMS": "1000",
+				"HTTP2_MAX_READ_FRAME_SIZE":    "16384",
+			},
+			wantH2C:          true,
+			wantMaxStreams:   1000,
+			wantMaxFrameSize: 16384,
+		},
+		{
+			name: "out-of-range values kept for Validate",
+			env: map[string]string{
+				"HTTP2_MAX_CONCURRENT_STREAMS": "0",
+				"HTTP2_MAX_READ_FRAME_SIZE":    "1024",
+			},
+			wantMaxStreams:   0,
+			wantMaxFrameSize: 1024,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := New()
+
+			if cfg.H2CEnabled != tt.wantH2C {
+				t.Errorf("H2CEnabled = %v, want %v", cfg.H2CEnabled, tt.wantH2C)
+			}
+			if cfg.HTTP2MaxConcurrentStreams != tt.wantMaxStreams {
+				t.Errorf("HTTP2MaxConcurrentStreams = %d, want %d", cfg.HTTP2MaxConcurrentStreams, tt.wantMaxStreams)
+			}
+			if cfg.HTTP2MaxReadFrameSize != tt.wantMaxFrameSize {
+				t.Errorf("HTTP2MaxReadFrameSize = %d, want %d", cfg.HTTP2MaxReadFrameSize, tt.wantMaxFrameSize)
+			}
+		})
+	}
+}
+
+func TestNew_ListenSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.Address() != ":8080" {
+		t.Errorf("Address() = %q by default, want %q", cfg.Address(), ":8080")
+	}
+	if cfg.UnixSocketMode != 0o660 {
+		t.Errorf("UnixSocketMode = %o by default, want 660", cfg.UnixSocketMode)
+	}
+
+	t.Setenv("LISTEN_ADDR", "unix:/run/echo/echo.sock")
+	t.Setenv("UNIX_SOCKET_MODE", "0600")
+
+	cfg = New()
+	if cfg.Address() != "unix:/run/echo/echo.sock" {
+		t.Errorf("Address() = %q, want %q", cfg.Address(), "unix:/run/echo/echo.sock")
+	}
+	if cfg.UnixSocketMode != 0o600 {
+		t.Errorf("UnixSocketMode = %o, want 600", cfg.UnixSocketMode)
+	}
+
+	for _, invalid := range []string{"rw-rw----", "0999", "01777", "0"} {
+		t.Setenv("UNIX_SOCKET_MODE", invalid)
+		if got := New().UnixSocketMode; got != 0o660 {
+			t.Errorf("UNIX_SOCKET_MODE=%q gave %o, want default 660", invalid, got)
+		}
+	}
+}
+
+func TestNew_Listeners(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("LISTENERS", "tls-public, ops, missing, ops, admin")
+	t.Setenv("LISTENER_TLS_PUBLIC_ADDR", ":8443")
+	t.Setenv("LISTENER_TLS_PUBLIC_TLS_CERT", "/etc/echo/cert.pem")
+	t.Setenv("LISTENER_TLS_PUBLIC_TLS_KEY", "/etc/echo/key.pem")
+	t.Setenv("LISTENER_OPS_ADDR", "unix:/run/echo/ops.sock")
+	t.Setenv("LISTENER_OPS_ROUTES", "health,metrics")
+	t.Setenv("LISTENER_OPS_MIDDLEWARE", "metrics,accesslog")
+	t.Setenv("LISTENER_ADMIN_ADDR", ":9999")
+
+	cfg := New()
+
+	want := []ListenerConfig{
+		{Name: "tls-public", Addr: ":8443", TLSCertFile: "/etc/echo/cert.pem", TLSKeyFile: "/etc/echo/key.pem"},
+		{Name: "ops", Addr: "unix:/run/echo/ops.sock", Routes: []string{"health", "metrics"}, Middleware: []string{"metrics", "accesslog"}},
+	}
+	if !slices.EqualFunc(cfg.Listeners, want, func(a, b ListenerConfig) bool {
+		return a.Name == b.Name && a.Addr == b.Addr && a.TLSCertFile == b.TLSCertFile && a.TLSKeyFile == b.TLSKeyFile &&
+			slices.Equal(a.Routes, b.Routes) && slices.Equal(a.Middleware, b.Middleware)
+	}) {
+		t.Errorf("Listeners = %+v, want %+v", cfg.Listeners, want)
+	}
+}
+
+func TestNew_ProxySettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.ProxyProtocol || len(cfg.TrustedProxies) != 0 {
+		t.Errorf("proxy defaults = %v/%v, want false/empty", cfg.ProxyProtocol, cfg.TrustedProxies)
+	}
+
+	t.Setenv("PROXY_PROTOCOL", "true")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.7, not-a-cidr, 2001:db8::/32, 172.16.5.4/12")
+
+	cfg = New()
+	if !cfg.ProxyProtocol {
+		t.Error("ProxyProtocol = false, want true")
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+	}
+	if !slices.Equal(cfg.TrustedProxies, want) {
+		t.Errorf("TrustedProxies = %v, want %v", cfg.TrustedProxies, want)
+	}
+}
+
+func TestNew_AccessLogSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.AccessLogEnabled || cfg.AccessLogSampleRate != 1 || cfg.AccessLogFormat != "json" {
+		t.Errorf("access log defaults = %v/%v/%q, want false/1/json", cfg.AccessLogEnabled, cfg.AccessLogSampleRate, cfg.AccessLogFormat)
+	}
+
+	t.Setenv("ACCESS_LOG_ENABLED", "true")
+	t.Setenv("ACCESS_LOG_SAMPLE_RATE", "0.25")
+	t.Setenv("ACCESS_LOG_FIELDS", "method, status,,route")
+	t.Setenv("ACCESS_LOG_FORMAT", "combined")
+	t.Setenv("ACCESS_LOG_FILE", "/var/log/echo/access.log")
+
+	cfg = New()
+	if !cfg.AccessLogEnabled {
+		t.Error("AccessLogEnabled = false, want true")
+	}
+	if cfg.AccessLogSampleRate != 0.25 {
+		t.Errorf("AccessLogSampleRate = %v, want 0.25", cfg.AccessLogSampleRate)
+	}
+	if want := []string{"method", "status", "route"}; !slices.Equal(cfg.AccessLogFields, want) {
+		t.Errorf("AccessLogFields = %v, want %v", cfg.AccessLogFields, want)
+	}
+	if cfg.AccessLogFormat != "combined" || cfg.AccessLogFile != "/var/log/echo/access.log" {
+		t.Errorf("AccessLogFormat/File = %q/%q", cfg.AccessLogFormat, cfg.AccessLogFile)
+	}
+}
+
+func TestNew_RequestIDSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.RequestIDHeader != "X-Request-ID" || cfg.RequestIDFormat != "uuidv7" {
+		t.Errorf("request ID defaults = %q/%q, want X-Request-ID/uuidv7", cfg.RequestIDHeader, cfg.RequestIDFormat)
+	}
+
+	t.Setenv("REQUEST_ID_HEADER", "X-Correlation-ID")
+	t.Setenv("REQUEST_ID_FORMAT", "ulid")
+
+	cfg = New()
+	if cfg.RequestIDHeader != "X-Correlation-ID" || cfg.RequestIDFormat != "ulid" {
+		t.Errorf("request ID settings = %q/%q, want X-Correlation-ID/ulid", cfg.RequestIDHeader, cfg.RequestIDFormat)
+	}
+}
+
+func TestNew_BodyLimitSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if cfg.MaxBodyBytes != 1<<20 {
+		t.Errorf("MaxBodyBytes = %d, want %d", cfg.MaxBodyBytes, 1<<20)
+	}
+	if cfg.RouteMaxBodyBytes != nil || cfg.RouteContentTypes != nil {
+		t.Errorf("route settings = %v/%v, want nil", cfg.RouteMaxBodyBytes, cfg.RouteContentTypes)
+	}
+
+	t.Setenv("MAX_BODY_BYTES", "2048")
+	t.Setenv("ROUTE_MAX_BODY_BYTES", "POST /api/v1/echo=4096, POST /upload=bad")
+	t.Setenv("ROUTE_CONTENT_TYPES", "POST /api/v1/echo=application/json | text/*")
+
+	cfg = New()
+	if cfg.MaxBodyBytes != 2048 {
+		t.Errorf("MaxBodyBytes = %d, want 2048", cfg.MaxBodyBytes)
+	}
+	if want := map[string]int64{"POST /api/v1/echo": 4096}; !maps.Equal(cfg.RouteMaxBodyBytes, want) {
+		t.Errorf("RouteMaxBodyBytes = %v, want %v", cfg.RouteMaxBodyBytes, want)
+	}
+	if got := cfg.RouteContentTypes["POST /api/v1/echo"]; !slices.Equal(got, []string{"application/json", "text/*"}) {
+		t.Errorf("RouteContentTypes = %v, want [application/json text/*]", got)
+	}
+}
+
+func TestNew_CORSSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if len(cfg.CORSAllowedOrigins) != 0 {
+		t.Errorf("CORSAllowedOrigins = %v by default, want empty", cfg.CORSAllowedOrigins)
+	}
+	if !slices.Equal(cfg.CORSAllowedMethods, []string{"GET", "POST", "HEAD"}) || cfg.CORSMaxAge != 600 {
+		t.Errorf("CORS defaults = %v/%d", cfg.CORSAllowedMethods, cfg.CORSMaxAge)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://*.example.com, http://localhost:3000")
+	t.Setenv("CORS_ALLOWED_METHODS", "GET,PUT")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "60")
+
+	cfg = New()
+	if want := []string{"https://*.example.com", "http://localhost:3000"}; !slices.Equal(cfg.CORSAllowedOrigins, want) {
+		t.Errorf("CORSAllowedOrigins = %v, want %v", cfg.CORSAllowedOrigins, want)
+	}
+	if !slices.Equal(cfg.CORSAllowedMethods, []string{"GET", "PUT"}) {
+		t.Errorf("CORSAllowedMethods = %v, want [GET PUT]", cfg.CORSAllowedMethods)
+	}
+	if !cfg.CORSAllowCredentials || cfg.CORSMaxAge != 60 {
+		t.Errorf("CORSAllowCredentials/MaxAge = %v/%d, want true/60", cfg.CORSAllowCredentials, cfg.CORSMaxAge)
+	}
+}
+
+func TestNew_CompressionSettings(t *testing.T) {
+	clearEnv(t)
+
+	cfg := New()
+	if !cfg.CompressionEnabled || cfg.CompressionMinSize != 1024 || !cfg.RequestDecompression {
+		t.Errorf("compression defaults = %v/%d/%v, want true/1024/true", cfg.CompressionEnabled, cfg.CompressionMinSize, cfg.RequestDecompression)
+	}
+	if !slices.Equal(cfg.CompressionEncodings, []string{"zstd", "br", "gzip"}) {
+		t.Errorf("CompressionEncodings = %v, want [zstd br gzip]", cfg.CompressionEncodings)
+	}
+
+	t.Setenv("COMPRESSION_ENABLED", "false")
+	t.Setenv("COMPRESSION_MIN_SIZE", "256")
+	t.Setenv("COMPRESSION_CONTENT_TYPES", "application/json")
+	t.Setenv("COMPRESSION_ENCODINGS", "gzip")
+	t.Setenv("REQUEST_DECOMPRESSION", "off")
+
+	cfg = New()
+	if cfg.CompressionEnabled || cfg.CompressionMinSize != 256 || cfg.RequestDecompression {
+		t.Errorf("compression settings = %v/%d/%v, want false/256/false", cfg.CompressionEnabled, cfg.CompressionMinSize, cfg.RequestDecompression)
+	}
+	if !slices.Equal(cfg.CompressionContentTypes, []string{"application/json"}) || !slices.Equal(cfg.CompressionEncodings, []string{"gzip"}) {
+		t.Errorf("CompressionContentTypes/Encodings = %v/%v", cfg.CompressionContentTypes, cfg.CompressionEncodings)
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "seconds", value: "5s", want: 5 * time.Second},
+		{name: "minutes", value: "2m", want: 2 * time.Minute},
+		{name: "zero", value: "0s", want: 0},
+		{name: "invalid returns default", value: "five", want: time.Second},
+		{name: "negative returns default", value: "-5s", want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("SHUTDOWN_DRAIN_DELAY", tt.value)
+
+			if got := getEnvDuration("SHUTDOWN_DRAIN_DELAY", time.Second); got != tt.want {
+				t.Errorf("getEnvDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 // clearEnv unsets relevant environment variables for clean test state
 func clearEnv(t *testing.T) {
 	t.Helper()
-	vars := []string{"PORT", "LOG_LEVEL", "AUTH_ENABLED", "API_KEYS", "TEST_BOOL"}
+	vars := []string{
+		"PORT", "LOG_LEVEL", "AUTH_ENABLED", "API_KEYS", "TEST_BOOL", "CONFIG_FILE",
+		"API_KEYS_FILE", "METRICS_TOKEN_FILE", "METRICS_PASSWORD_FILE", "ADMIN_TOKEN_FILE",
+		"MAX_BODY_BYTES", "ROUTE_MAX_BODY_BYTES", "ROUTE_CONTENT_TYPES",
+		"COMPRESSION_ENABLED", "COMPRESSION_MIN_SIZE", "COMPRESSION_CONTENT_TYPES", "COMPRESSION_ENCODINGS",
+		"REQUEST_DECOMPRESSION",
+		"CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_METHODS", "CORS_ALLOWED_HEADERS", "CORS_EXPOSED_HEADERS",
+		"CORS_ALLOW_CREDENTIALS", "CORS_MAX_AGE",
+		"REQUEST_ID_HEADER", "REQUEST_ID_FORMAT",
+		"MOCK_ROUTES_FILE", "MOCK_ROUTES_RELOAD_INTERVAL",
+		"LOG_FORMAT", "LOG_OUTPUT", "LOG_MAX_SIZE", "LOG_MAX_BACKUPS", "LOG_SOURCE",
+		"LOG_SERVICE", "LOG_ENVIRONMENT", "LOG_INSTANCE_ID",
+		"ACCESS_LOG_ENABLED", "ACCESS_LOG_SAMPLE_RATE", "ACCESS_LOG_FIELDS", "ACCESS_LOG_FORMAT", "ACCESS_LOG_FILE",
+		"READ_TIMEOUT", "READ_HEADER_TIMEOUT", "WRITE_TIMEOUT", "IDLE_TIMEOUT", "MAX_HEADER_BYTES",
+		"H2C_ENABLED", "HTTP2_MAX_CONCURRENT_STREAMS", "HTTP2_MAX_READ_FRAME_SIZE",
+		"TLS_CERT_FILE", "TLS_KEY_FILE", "HTTP3_ENABLED", "HTTP3_ADDR",
+		"SHUTDOWN_TIMEOUT", "SHUTDOWN_DRAIN_DELAY", "LISTEN_ADDR", "UNIX_SOCKET_MODE",
+		"LISTENERS", "LISTENER_TLS_PUBLIC_ADDR", "LISTENER_TLS_PUBLIC_TLS_CERT", "LISTENER_TLS_PUBLIC_TLS_KEY",
+		"LISTENER_OPS_ADDR", "LISTENER_OPS_ROUTES", "LISTENER_OPS_MIDDLEWARE", "LISTENER_ADMIN_ADDR",
+		"LISTENER_OPS_PROXY_PROTOCOL",
+		"PROXY_PROTOCOL", "TRUSTED_PROXIES",
+		"ADMIN_PORT", "ADMIN_TOKEN", "METRICS_ENABLED", "METRICS_TOKEN", "METRICS_USERNAME", "METRICS_PASSWORD",
+		"METRICS_BUCKETS", "METRICS_NATIVE_HISTOGRAMS", "METRICS_NATIVE_BUCKET_FACTOR", "METRICS_NATIVE_MAX_BUCKETS",
+	}
 	for _, v := range vars {
 		os.Unsetenv(v)
 	}
 }
+
+func TestNew_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		return path
+	}
+	keysFile := writeSecret("api_keys", "# rotated 2024-06-01\nkey-one\n\n  key-two  \n# key-old\n")
+	tokenFile := writeSecret("token", "scrape-token\n")
+	twoLines := writeSecret("two", "first\nsecond\n")
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantKeys     []string
+		wantNotKeys  []string
+		wantToken    string
+		wantPassword string
+		wantProblems []string
+	}{
+		{
+			name:        "API keys from a file skip comments and blank lines",
+			env:         map[string]string{"API_KEYS_FILE": keysFile},
+			wantKeys:    []string{"key-one", "key-two"},
+			wantNotKeys: []string{"# rotated 2024-06-01", "key-old", ""},
+		},
+		{
+			name:      "single-value secrets from files",
+			env:       map[string]string{"METRICS_TOKEN_FILE": tokenFile, "METRICS_USERNAME": "prom", "METRICS_PASSWORD_FILE": tokenFile},
+			wantToken: "scrape-token", wantPassword: "scrape-token",
+		},
+		{
+			name:         "both the variable and its file",
+			env:          map[string]string{"API_KEYS": "inline", "API_KEYS_FILE": keysFile},
+			wantNotKeys:  []string{"key-one"},
+			wantProblems: []string{"API_KEYS, API_KEYS_FILE: set only one of them"},
+		},
+		{
+			name:         "missing file",
+			env:          map[string]string{"METRICS_TOKEN_FILE": filepath.Join(dir, "missing")},
+			wantProblems: []string{"METRICS_TOKEN_FILE: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		},
+		{
+			name:         "more than one value",
+			env:          map[string]string{"METRICS_PASSWORD_FILE": twoLines},
+			wantProblems: []string{"METRICS_PASSWORD_FILE: expected one value, found 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg := New()
+
+			for _, key := range tt.wantKeys {
+				if !cfg.ValidateAPIKey(key) {
+					t.Errorf("ValidateAPIKey(%q) = false, want true", key)
+				}
+			}
+			for _, key := range tt.wantNotKeys {
+				if cfg.ValidateAPIKey(key) {
+					t.Errorf("ValidateAPIKey(%q) = true, want false", key)
+				}
+			}
+			if cfg.MetricsToken != tt.wantToken {
+				t.Errorf("MetricsToken = %q, want %q", cfg.MetricsToken, tt.wantToken)
+			}
+			if cfg.MetricsPassword != tt.wantPassword {
+				t.Errorf("MetricsPassword = %q, want %q", cfg.MetricsPassword, tt.wantPassword)
+			}
+
+			err := cfg.Validate()
+			if tt.wantProblems == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !slices.Equal(verr.Problems, tt.wantProblems) {
+				t.Errorf("Validate() error = %v, want problems %q", err, tt.wantProblems)
+			}
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// ErrUnknownKey is returned when a configuration file contains a key the server does not recognise
+var ErrUnknownKey = errors.New("unknown key")
+
+// FileConfig is the layout of a configuration file, keys that are absent keep their defaults
+type FileConfig struct {
+	Server      ServerConfig      `config:"server"`
+	Auth        AuthConfig        `config:"auth"`
+	Metrics     MetricsConfig     `config:"metrics"`
+	Logging     LoggingConfig     `config:"logging"`
+	Compression CompressionConfig `config:"compression"`
+	CORS        CORSConfig        `config:"cors"`
+	RequestID   RequestIDConfig   `config:"request_id"`
+	// Listeners replaces the additional listeners, like LISTENERS and its LISTENER_<NAME>_* variables
+	Listeners []ListenerConfig `config:"listeners" env:"LISTENERS"`
+}
+
+// Port is a TCP port in a configuration file, written either as a number or as a string
+type Port string
+
+// ServerConfig is the server section of a configuration file
+type ServerConfig struct {
+	Port           *Port   `config:"port" env:"PORT"`
+	ListenAddr     *string `config:"listen_addr" env:"LISTEN_ADDR"`
+	AdminPort      *Port   `config:"admin_port" env:"ADMIN_PORT"`
+	UnixSocketMode *string `config:"unix_socket_mode" env:"UNIX_SOCKET_MODE"`
+
+	AdminToken     *string `config:"admin_token" env:"ADMIN_TOKEN"`
+	AdminTokenFile *string `config:"admin_token_file" env:"ADMIN_TOKEN"`
+
+	ReadTimeout        *time.Duration `config:"read_timeout" env:"READ_TIMEOUT"`
+	ReadHeaderTimeout  *time.Duration `config:"read_header_timeout" env:"READ_HEADER_TIMEOUT"`
+	WriteTimeout       *time.Duration `config:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout        *time.Duration `config:"idle_timeout" env:"IDLE_TIMEOUT"`
+	ShutdownTimeout    *time.Duration `config:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainDelay *time.Duration `config:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY"`
+	MaxHeaderBytes     *int           `config:"max_header_bytes" env:"MAX_HEADER_BYTES"`
+	MaxBodyBytes       *int64         `config:"max_body_bytes" env:"MAX_BODY_BYTES"`
+
+	// RouteMaxBodyBytes and RouteContentTypes are keyed by route pattern such as "POST /api/v1/echo"
+	RouteMaxBodyBytes map[string]int64    `config:"route_max_body_bytes" env:"ROUTE_MAX_BODY_BYTES"`
+	RouteContentTypes map[string][]string `config:"route_content_types" env:"ROUTE_CONTENT_TYPES"`
+
+	TLSCertFile               *string `config:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile                *string `config:"tls_key_file" env:"TLS_KEY_FILE"`
+	HTTP3Enabled              *bool   `config:"http3_enabled" env:"HTTP3_ENABLED"`
+	HTTP3Addr                 *string `config:"http3_addr" env:"HTTP3_ADDR"`
+	H2CEnabled                *bool   `config:"h2c_enabled" env:"H2C_ENABLED"`
+	HTTP2MaxConcurrentStreams *int    `config:"http2_max_concurrent_streams" env:"HTTP2_MAX_CONCURRENT_STREAMS"`
+	HTTP2MaxReadFrameSize     *int    `config:"http2_max_read_frame_size" env:"HTTP2_MAX_READ_FRAME_SIZE"`
+
+	ProxyProtocol  *bool    `config:"proxy_protocol" env:"PROXY_PROTOCOL"`
+	TrustedProxies []string `config:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	MockRoutesFile           *string        `config:"mock_routes_file" env:"MOCK_ROUTES_FILE"`
+	MockRoutesReloadInterval *time.Duration `config:"mock_routes_reload_interval" env:"MOCK_ROUTES_RELOAD_INTERVAL"`
+}
+
+// AuthConfig is the auth section of a configuration file
+type AuthConfig struct {
+	Enabled *bool    `config:"enabled" env:"AUTH_ENABLED"`
+	APIKeys []string `config:"api_keys" env:"API_KEYS"`
+	// APIKeysFile names a secret file with one key per line
+	APIKeysFile *string `config:"api_keys_file" env:"API_KEYS"`
+}
+
+// MetricsConfig is the metrics section of a configuration file
+type MetricsConfig struct {
+	Enabled            *bool     `config:"enabled" env:"METRICS_ENABLED"`
+	Token              *string   `config:"token" env:"METRICS_TOKEN"`
+	TokenFile          *string   `config:"token_file" env:"METRICS_TOKEN"`
+	Username           *string   `config:"username" env:"METRICS_USERNAME"`
+	Password           *string   `config:"password" env:"METRICS_PASSWORD"`
+	PasswordFile       *string   `config:"password_file" env:"METRICS_PASSWORD"`
+	Buckets            []float64 `config:"buckets" env:"METRICS_BUCKETS"`
+	NativeHistograms   *bool     `config:"native_histograms" env:"METRICS_NATIVE_HISTOGRAMS"`
+	NativeBucketFactor *float64  `config:"native_bucket_factor" env:"METRICS_NATIVE_BUCKET_FACTOR"`
+	NativeMaxBuckets   *uint32   `config:"native_max_buckets" env:"METRICS_NATIVE_MAX_BUCKETS"`
+}
+
+// LoggingConfig is the logging section of a configuration file
+type LoggingConfig struct {
+	Level       *string `config:"level" env:"LOG_LEVEL"`
+	Format      *string `config:"format" env:"LOG_FORMAT"`
+	Output      *string `config:"output" env:"LOG_OUTPUT"`
+	MaxSize     *int64  `config:"max_size" env:"LOG_MAX_SIZE"`
+	MaxBackups  *int    `config:"max_backups" env:"LOG_MAX_BACKUPS"`
+	Source      *bool   `config:"source" env:"LOG_SOURCE"`
+	Service     *string `config:"service" env:"LOG_SERVICE"`
+	Environment *string `config:"environment" env:"LOG_ENVIRONMENT"`
+	InstanceID  *string `config:"instance_id" env:"LOG_INSTANCE_ID"`
+
+	AccessLog AccessLogConfig `config:"access_log"`
+}
+
+// AccessLogConfig is the logging.access_log section of a configuration file
+type AccessLogConfig struct {
+	Enabled    *bool    `config:"enabled" env:"ACCESS_LOG_ENABLED"`
+	SampleRate *float64 `config:"sample_rate" env:"ACCESS_LOG_SAMPLE_RATE"`
+	Fields     []string `config:"fields" env:"ACCESS_LOG_FIELDS"`
+	Format     *string  `config:"format" env:"ACCESS_LOG_FORMAT"`
+	File       *string  `config:"file" env:"ACCESS_LOG_FILE"`
+}
+
+// CompressionConfig is the compression section of a configuration file
+type CompressionConfig struct {
+	Enabled              *bool    `config:"enabled" env:"COMPRESSION_ENABLED"`
+	MinSize              *int     `config:"min_size" env:"COMPRESSION_MIN_SIZE"`
+	ContentTypes         []string `config:"content_types" env:"COMPRESSION_CONTENT_TYPES"`
+	Encodings            []string `config:"encodings" env:"COMPRESSION_ENCODINGS"`
+	RequestDecompression *bool    `config:"request_decompression" env:"REQUEST_DECOMPRESSION"`
+}
+
+// CORSConfig is the cors section of a configuration file
+type CORSConfig struct {
+	AllowedOrigins   []string `config:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string `config:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string `config:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string `config:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials *bool    `config:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           *int     `config:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// RequestIDConfig is the request_id section of a configuration file
+type RequestIDConfig struct {
+	Header *string `config:"header" env:"REQUEST_ID_HEADER"`
+	Format *string `config:"format" env:"REQUEST_ID_FORMAT"`
+}
+
+// ReadFile parses a YAML, TOML or JSON configuration file, chosen by its extension
+// Errors name the offending key, such as "server.read_timeout: invalid duration"
+func ReadFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file extension %q, use .yaml, .yml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	fc := &FileConfig{}
+	if raw != nil {
+		if err := decodeValue("", raw, reflect.ValueOf(fc).Elem()); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return fc, nil
+}
+
+// apply copies the keys set in the file onto cfg
+func (f *FileConfig) apply(cfg *Config) error {
+	s := f.Server
+	if s.Port != nil {
+		cfg.Port = string(*s.Port)
+	}
+	set(&cfg.ListenAddr, s.ListenAddr)
+	if s.AdminPort != nil {
+		cfg.AdminPort = string(*s.AdminPort)
+	}
+	if s.UnixSocketMode != nil {
+		mode, err := parseFileMode(*s.UnixSocketMode)
+		if err != nil {
+			return fmt.Errorf("server.unix_socket_mode: %w", err)
+		}
+		cfg.UnixSocketMode = mode
+	}
+	if err := setSecret(&cfg.AdminToken, "server.admin_token", s.AdminToken, s.AdminTokenFile); err != nil {
+		return err
+	}
+	set(&cfg.ReadTimeout, s.ReadTimeout)
+	set(&cfg.ReadHeaderTimeout, s.ReadHeaderTimeout)
+	set(&cfg.WriteTimeout, s.WriteTimeout)
+	set(&cfg.IdleTimeout, s.IdleTimeout)
+	set(&cfg.ShutdownTimeout, s.ShutdownTimeout)
+	set(&cfg.ShutdownDrainDelay, s.ShutdownDrainDelay)
+	set(&cfg.MaxHeaderBytes, s.MaxHeaderBytes)
+	set(&cfg.MaxBodyBytes, s.MaxBodyBytes)
+	if s.RouteMaxBodyBytes != nil {
+		cfg.RouteMaxBodyBytes = s.RouteMaxBodyBytes
+	}
+	if s.RouteContentTypes != nil {
+		cfg.RouteContentTypes = s.RouteContentTypes
+	}
+	set(&cfg.TLSCertFile, s.TLSCertFile)
+	set(&cfg.TLSKeyFile, s.TLSKeyFile)
+	set(&cfg.HTTP3Enabled, s.HTTP3Enabled)
+	set(&cfg.HTTP3Addr, s.HTTP3Addr)
+	set(&cfg.H2CEnabled, s.H2CEnabled)
+	set(&cfg.HTTP2MaxConcurrentStreams, s.HTTP2MaxConcurrentStreams)
+	set(&cfg.HTTP2MaxReadFrameSize, s.HTTP2MaxReadFrameSize)
+	set(&cfg.ProxyProtocol, s.ProxyProtocol)
+	set(&cfg.MockRoutesFile, s.MockRoutesFile)
+	set(&cfg.MockRoutesReloadInterval, s.MockRoutesReloadInterval)
+	if s.TrustedProxies != nil {
+		prefixes, err := parsePrefixes(s.TrustedProxies)
+		if err != nil {
+			return fmt.Errorf("server.trusted_proxies: %w", err)
+		}
+		cfg.TrustedProxies = prefixes
+	}
+
+	set(&cfg.AuthEnabled, f.Auth.Enabled)
+	if f.Auth.APIKeys != nil {
+		cfg.setAPIKeys(f.Auth.APIKeys)
+	}
+	if f.Auth.APIKeysFile != nil {
+		if f.Auth.APIKeys != nil {
+			return errors.New("auth.api_keys, auth.api_keys_file: set only one of them")
+		}
+		keys, err := readSecretFile(*f.Auth.APIKeysFile)
+		if err != nil {
+			return fmt.Errorf("auth.api_keys_file: %w", err)
+		}
+		cfg.setAPIKeys(keys)
+	}
+
+	m := f.Metrics
+	if m.Enabled != nil {
+		cfg.MetricsDisabled = !*m.Enabled
+	}
+	set(&cfg.MetricsUsername, m.Username)
+	if err := setSecret(&cfg.MetricsToken, "metrics.token", m.Token, m.TokenFile); err != nil {
+		return err
+	}
+	if err := setSecret(&cfg.MetricsPassword, "metrics.password", m.Password, m.PasswordFile); err != nil {
+		return err
+	}
+	if m.Buckets != nil {
+		cfg.MetricsBuckets = m.Buckets
+	}
+	set(&cfg.MetricsNativeHistograms, m.NativeHistograms)
+	set(&cfg.MetricsNativeBucketFactor, m.NativeBucketFactor)
+	set(&cfg.MetricsNativeMaxBuckets, m.NativeMaxBuckets)
+
+	l := f.Logging
+	set(&cfg.LogLevel, l.Level)
+	set(&cfg.LogFormat, l.Format)
+	set(&cfg.LogOutput, l.Output)
+	set(&cfg.LogMaxSize, l.MaxSize)
+	set(&cfg.LogMaxBackups, l.MaxBackups)
+	set(&cfg.LogSource, l.Source)
+	set(&cfg.LogService, l.Service)
+	set(&cfg.LogEnvironment, l.Environment)
+	set(&cfg.LogInstanceID, l.InstanceID)
+	set(&cfg.AccessLogEnabled, l.AccessLog.Enabled)
+	set(&cfg.AccessLogSampleRate, l.AccessLog.SampleRate)
+	if l.AccessLog.Fields != nil {
+		cfg.AccessLogFields = l.AccessLog.Fields
+	}
+	set(&cfg.AccessLogFormat, l.AccessLog.Format)
+	set(&cfg.AccessLogFile, l.AccessLog.File)
+
+	c := f.Compression
+	set(&cfg.CompressionEnabled, c.Enabled)
+	set(&cfg.CompressionMinSize, c.MinSize)
+	setList(&cfg.CompressionContentTypes, c.ContentTypes)
+	setList(&cfg.CompressionEncodings, c.Encodings)
+	set(&cfg.RequestDecompression, c.RequestDecompression)
+
+	cors := f.CORS
+	setList(&cfg.CORSAllowedOrigins, cors.AllowedOrigins)
+	setList(&cfg.CORSAllowedMethods, cors.AllowedMethods)
+	setList(&cfg.CORSAllowedHeaders, cors.AllowedHeaders)
+	setList(&cfg.CORSExposedHeaders, cors.ExposedHeaders)
+	set(&cfg.CORSAllowCredentials, cors.AllowCredentials)
+	set(&cfg.CORSMaxAge, cors.MaxAge)
+
+	set(&cfg.RequestIDHeader, f.RequestID.Header)
+	set(&cfg.RequestIDFormat, f.RequestID.Format)
+
+	if f.Listeners != nil {
+		if err := checkListeners(f.Listeners); err != nil {
+			return err
+		}
+		cfg.Listeners = f.Listeners
+	}
+	return nil
+}
+
+// checkListeners rejects file listeners the server could not start, matching the LISTENERS checks
+func checkListeners(listeners []ListenerConfig) error {
+	seen := make(map[string]bool)
+	for i, l := range listeners {
+		key := fmt.Sprintf("listeners[%d]", i)
+		switch {
+		case l.Name == "":
+			return fmt.Errorf("%s.name: required", key)
+		case l.Name == "main" || l.Name == "admin":
+			return fmt.Errorf("%s.name: listener name %q is reserved", key, l.Name)
+		case seen[l.Name]:
+			return fmt.Errorf("%s.name: duplicate listener %q", key, l.Name)
+		case strings.TrimSpace(l.Addr) == "":
+			return fmt.Errorf("%s.addr: required for listener %q", key, l.Name)
+		}
+		seen[l.Name] = true
+	}
+	return nil
+}
+
+// setSecret assigns a secret set either inline under key or in the file named by key+"_file"
+func setSecret(dst *string, key string, value, file *string) error {
+	if file == nil {
+		set(dst, value)
+		return nil
+	}
+	if value != nil {
+		return fmt.Errorf("%s, %s_file: set only one of them", key, key)
+	}
+
+	lines, err := readSecretFile(*file)
+	if err != nil {
+		return fmt.Errorf("%s_file: %w", key, err)
+	}
+	if len(lines) != 1 {
+		return fmt.Errorf("%s_file: expected one value, found %d", key, len(lines))
+	}
+	*dst = lines[0]
+	return nil
+}
+
+// set assigns *src to *dst when the file set the key
+func set[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// setList assigns src to dst when the file set the key, an empty list clears dst
+func setList[T any](dst *[]T, src []T) {
+	if src != nil {
+		*dst = src
+	}
+}
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	portType     = reflect.TypeFor[Port]()
+)
+
+// decodeValue stores src, a value decoded by one of the file parsers, into dst
+// key is the dotted path of src and prefixes every error
+func decodeValue(key string, src any, dst reflect.Value) error {
+	if dst.Type() == durationType {
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a duration such as \"15s\", got %s", key, describe(src))
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%s: invalid duration %q", key, s)
+		}
+		dst.SetInt(int64(d))
+		return nil
+	}
+	if dst.Type() == portType {
+		// Strings are checked by Validate so they are reported like an invalid PORT
+		if s, ok := src.(string); ok {
+			dst.SetString(s)
+			return nil
+		}
+		n, ok := toInt(src)
+		if !ok || n < 0 || n > 65535 {
+			return fmt.Errorf("%s: expected a port number between 0 and 65535, got %s", key, describe(src))
+		}
+		dst.SetString(strconv.FormatInt(n, 10))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		v := reflect.New(dst.Type().Elem())
+		if err := decodeValue(key, src, v.Elem()); err != nil {
+			return err
+		}
+		dst.Set(v)
+	case reflect.Struct:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %s", key, describe(src))
+		}
+		fields := make(map[string]int)
+		for i := range dst.NumField() {
+			fields[dst.Type().Field(i).Tag.Get("config")] = i
+		}
+		// Sorted keys report the same error for the same file on every run
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			path := k
+			if key != "" {
+				path = key + "." + k
+			}
+			i, ok := fields[k]
+			if !ok {
+				return fmt.Errorf("%s: %w", path, ErrUnknownKey)
+			}
+			if err := decodeValue(path, m[k], dst.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := src.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a section, got %s", key, describe(src))
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			v := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(fmt.Sprintf("%s[%q]", key, k), m[k], v); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(k), v)
+		}
+		dst.Set(out)
+	case reflect.Slice:
+		items, ok := src.([]any)
+		if tables, isTables := src.([]map[string]any); isTables {
+			// TOML decodes arrays of tables such as [[listeners]] into their own slice type
+			items, ok = make([]any, len(tables)), true
+			for i, table := range tables {
+				items[i] = table
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: expected a list, got %s", key, describe(src))
+		}
+		list := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(fmt.Sprintf("%s[%d]", key, i), item, list.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(list)
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %s", key, describe(src))
+		}
+		dst.SetString(s)
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected true or false, got %s", key, describe(src))
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, ok := toInt(src)
+		if !ok || n < 0 || dst.OverflowInt(n) {
+			return fmt.Errorf("%s: expected a non-negative integer, got %s", key, describe(src))
+		}
+		dst.SetInt(n)
+	case reflect.Uint32:
+		n, ok := toInt(src)
+		if !ok || n < 0 || dst.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%s: expected a non-negative integer, got %s", key, describe(src))
+		}
+		dst.SetUint(uint64(n))
+	case reflect.Float64:
+		f, ok := toFloat(src)
+		if !ok {
+			return fmt.Errorf("%s: expected a number, got %s", key, describe(src))
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("%s: unsupported type %s", key, dst.Type())
+	}
+	return nil
+}
+
+// toInt converts the integer types produced by the YAML, TOML and JSON parsers
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), n <= 1<<63-1
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toFloat converts the numeric types produced by the YAML, TOML and JSON parsers
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		i, ok := toInt(v)
+		return float64(i), ok
+	}
+}
+
+// describe names the type of a decoded value for error messages
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case int, int64, uint64, float64, json.Number:
+		return fmt.Sprintf("number %v", v)
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "a section"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
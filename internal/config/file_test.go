@@ -0,0 +1,657 @@
+package config
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a file named name in a temporary directory and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestReadFile_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+server:
+  port: 9090
+  read_timeout: 30s
+  max_body_bytes: 2048
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+auth:
+  enabled: true
+  api_keys: [key1, key2]
+metrics:
+  enabled: false
+  buckets: [0.1, 1]
+  native_max_buckets: 100
+logging:
+  level: debug
+  access_log:
+    sample_rate: 0.5
+    fields: [method, path]
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[server]
+port = 9090
+read_timeout = "30s"
+max_body_bytes = 2048
+trusted_proxies = ["10.0.0.0/8", "192.168.1.1"]
+
+[auth]
+enabled = true
+api_keys = ["key1", "key2"]
+
+[metrics]
+enabled = false
+buckets = [0.1, 1]
+native_max_buckets = 100
+
+[logging]
+level = "debug"
+
+[logging.access_log]
+sample_rate = 0.5
+fields = ["method", "path"]
+`,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{
+  "server": {"port": "9090", "read_timeout": "30s", "max_body_bytes": 2048, "trusted_proxies": ["10.0.0.0/8", "192.168.1.1"]},
+  "auth": {"enabled": true, "api_keys": ["key1", "key2"]},
+  "metrics": {"enabled": false, "buckets": [0.1, 1], "native_max_buckets": 100},
+  "logging": {"level": "debug", "access_log": {"sample_rate": 0.5, "fields": ["method", "path"]}}
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			cfg, err := Load(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Port != "9090" {
+				t.Errorf("Port = %q, want %q", cfg.Port, "9090")
+			}
+			if cfg.ReadTimeout != 30*time.Second {
+				t.Errorf("ReadTimeout = %v, want %v", cfg.ReadTimeout, 30*time.Second)
+			}
+			if cfg.MaxBodyBytes != 2048 {
+				t.Errorf("MaxBodyBytes = %d, want 2048", cfg.MaxBodyBytes)
+			}
+			wantProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}
+			if !slices.Equal(cfg.TrustedProxies, wantProxies) {
+				t.Errorf("TrustedProxies = %v, want %v", cfg.TrustedProxies, wantProxies)
+			}
+			if !cfg.AuthEnabled || !cfg.ValidateAPIKey("key1") || !cfg.ValidateAPIKey("key2") {
+				t.Errorf("auth = %v with %d keys, want enabled with key1 and key2", cfg.AuthEnabled, cfg.APIKeyCount())
+			}
+			if !cfg.MetricsDisabled {
+				t.Error("MetricsDisabled = false, want true")
+			}
+			if !slices.Equal(cfg.MetricsBuckets, []float64{0.1, 1}) {
+				t.Errorf("MetricsBuckets = %v, want [0.1 1]", cfg.MetricsBuckets)
+			}
+			if cfg.MetricsNativeMaxBuckets != 100 {
+				t.Errorf("MetricsNativeMaxBuckets = %d, want 100", cfg.MetricsNativeMaxBuckets)
+			}
+			if cfg.LogLevel != "debug" {
+				t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, "debug")
+			}
+			if cfg.AccessLogSampleRate != 0.5 {
+				t.Errorf("AccessLogSampleRate = %v, want 0.5", cfg.AccessLogSampleRate)
+			}
+			if !slices.Equal(cfg.AccessLogFields, []string{"method", "path"}) {
+				t.Errorf("AccessLogFields = %v, want [method path]", cfg.AccessLogFields)
+			}
+
+			// Keys absent from the file keep their defaults
+			if cfg.WriteTimeout != DefaultWriteTimeout {
+				t.Errorf("WriteTimeout = %v, want default %v", cfg.WriteTimeout, DefaultWriteTimeout)
+			}
+			if cfg.AccessLogEnabled {
+				t.Error("AccessLogEnabled = true, want default false")
+			}
+		})
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantErr     error
+		wantMessage string
+	}{
+		{
+			name:        "unknown top-level section",
+			file:        "config.yaml",
+			content:     "servers:\n  port: \"80\"\n",
+			wantErr:     ErrUnknownKey,
+			wantMessage: "servers: unknown key",
+		},
+		{
+			name:        "unknown nested key",
+			file:        "config.toml",
+			content:     "[server]\nread_timout = \"5s\"\n",
+			wantErr:     ErrUnknownKey,
+			wantMessage: "server.read_timout: unknown key",
+		},
+		{
+			name:        "unknown key in JSON",
+			file:        "config.json",
+			content:     `{"logging": {"access_log": {"colour": true}}}`,
+			wantErr:     ErrUnknownKey,
+			wantMessage: "logging.access_log.colour: unknown key",
+		},
+		{
+			name:        "malformed duration",
+			file:        "config.yaml",
+			content:     "server:\n  write_timeout: soon\n",
+			wantMessage: `server.write_timeout: invalid duration "soon"`,
+		},
+		{
+			name:        "wrong type",
+			file:        "config.json",
+			content:     `{"auth": {"enabled": "yes"}}`,
+			wantMessage: `auth.enabled: expected true or false, got string "yes"`,
+		},
+		{
+			name:        "negative integer",
+			file:        "config.toml",
+			content:     "[server]\nmax_header_bytes = -1\n",
+			wantMessage: "server.max_header_bytes: expected a non-negative integer",
+		},
+		{
+			name:        "list element",
+			file:        "config.yaml",
+			content:     "metrics:\n  buckets: [0.1, fast]\n",
+			wantMessage: `metrics.buckets[1]: expected a number, got string "fast"`,
+		},
+		{
+			name:        "section given a scalar",
+			file:        "config.yaml",
+			content:     "auth: true\n",
+			wantMessage: "auth: expected a section, got boolean true",
+		},
+		{
+			name:        "invalid trusted proxy",
+			file:        "config.yaml",
+			content:     "server:\n  trusted_proxies: [10.0.0.0/33]\n",
+			wantMessage: `server.trusted_proxies: invalid CIDR or IP "10.0.0.0/33"`,
+		},
+		{
+			name:        "port out of range",
+			file:        "config.yaml",
+			content:     "server:\n  port: 80800\n",
+			wantMessage: "server.port: expected a port number between 0 and 65535, got number 80800",
+		},
+		{
+			name:        "invalid unix socket mode",
+			file:        "config.yaml",
+			content:     "server:\n  unix_socket_mode: \"0999\"\n",
+			wantMessage: `server.unix_socket_mode: invalid file mode "0999"`,
+		},
+		{
+			name:        "route map value",
+			file:        "config.json",
+			content:     `{"server": {"route_max_body_bytes": {"POST /api/v1/echo": "big"}}}`,
+			wantMessage: `server.route_max_body_bytes["POST /api/v1/echo"]: expected a non-negative integer, got string "big"`,
+		},
+		{
+			name:        "unknown listener key",
+			file:        "config.toml",
+			content:     "[[listeners]]\nname = \"ops\"\nport = 9000\n",
+			wantErr:     ErrUnknownKey,
+			wantMessage: "listeners[0].port: unknown key",
+		},
+		{
+			name:        "reserved listener name",
+			file:        "config.yaml",
+			content:     "listeners:\n  - name: admin\n    addr: :9000\n",
+			wantMessage: `listeners[0].name: listener name "admin" is reserved`,
+		},
+		{
+			name:        "duplicate listener",
+			file:        "config.yaml",
+			content:     "listeners:\n  - name: ops\n    addr: :9000\n  - name: ops\n    addr: :9001\n",
+			wantMessage: `listeners[1].name: duplicate listener "ops"`,
+		},
+		{
+			name:        "listener without address",
+			file:        "config.yaml",
+			content:     "listeners:\n  - name: ops\n",
+			wantMessage: `listeners[0].addr: required for listener "ops"`,
+		},
+		{
+			name:        "syntax error",
+			file:        "config.json",
+			content:     `{"server": `,
+			wantMessage: "unexpected EOF",
+		},
+		{
+			name:        "unsupported extension",
+			file:        "config.ini",
+			content:     "port=80",
+			wantMessage: `unsupported config file extension ".ini"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			path := writeConfigFile(t, tt.file, tt.content)
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("Load() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("Load() error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+			if !strings.HasPrefix(err.Error(), path+": ") {
+				t.Errorf("Load() error = %q, want it prefixed with the file path", err)
+			}
+		})
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "9090"
+  idle_timeout: 2m
+auth:
+  enabled: true
+  api_keys: [file-key]
+logging:
+  level: warn
+`)
+
+	tests := []struct {
+		name       string
+		path       string
+		env        map[string]string
+		wantPort   string
+		wantLevel  string
+		wantIdle   time.Duration
+		wantAuth   bool
+		wantKey    string
+		wantNotKey string
+	}{
+		{
+			name:      "defaults without a file",
+			wantPort:  "8080",
+			wantLevel: "info",
+			wantIdle:  DefaultIdleTimeout,
+		},
+		{
+			name:      "file overrides defaults",
+			path:      path,
+			wantPort:  "9090",
+			wantLevel: "warn",
+			wantIdle:  2 * time.Minute,
+			wantAuth:  true,
+			wantKey:   "file-key",
+		},
+		{
+			name:      "CONFIG_FILE locates the file",
+			env:       map[string]string{"CONFIG_FILE": path},
+			wantPort:  "9090",
+			wantLevel: "warn",
+			wantIdle:  2 * time.Minute,
+			wantAuth:  true,
+			wantKey:   "file-key",
+		},
+		{
+			name: "environment overrides the file",
+			path: path,
+			env: map[string]string{
+				"PORT":         "7070",
+				"IDLE_TIMEOUT": "30s",
+				"AUTH_ENABLED": "false",
+				"API_KEYS":     "env-key",
+			},
+			wantPort:   "7070",
+			wantLevel:  "warn",
+			wantIdle:   30 * time.Second,
+			wantKey:    "env-key",
+			wantNotKey: "file-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load(tt.path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %q, want %q", cfg.Port, tt.wantPort)
+			}
+			if cfg.LogLevel != tt.wantLevel {
+				t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, tt.wantLevel)
+			}
+			if cfg.IdleTimeout != tt.wantIdle {
+				t.Errorf("IdleTimeout = %v, want %v", cfg.IdleTimeout, tt.wantIdle)
+			}
+			if cfg.AuthEnabled != tt.wantAuth {
+				t.Errorf("AuthEnabled = %v, want %v", cfg.AuthEnabled, tt.wantAuth)
+			}
+			if tt.wantKey != "" && !cfg.ValidateAPIKey(tt.wantKey) {
+				t.Errorf("ValidateAPIKey(%q) = false, want true", tt.wantKey)
+			}
+			if tt.wantNotKey != "" && cfg.ValidateAPIKey(tt.wantNotKey) {
+				t.Errorf("ValidateAPIKey(%q) = true, want false", tt.wantNotKey)
+			}
+		})
+	}
+}
+
+func TestReadFile_Sections(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+server:
+  admin_port: 9091
+  unix_socket_mode: "0600"
+  route_max_body_bytes:
+    POST /api/v1/echo: 4096
+  route_content_types:
+    POST /api/v1/echo: [application/json, text/*]
+compression:
+  enabled: false
+  min_size: 512
+  encodings: [gzip]
+  request_decompression: false
+cors:
+  allowed_origins: [https://app.example.com]
+  allow_credentials: true
+  max_age: 60
+request_id:
+  header: X-Correlation-ID
+  format: ulid
+listeners:
+  - name: internal
+    addr: 127.0.0.1:8081
+    routes: [health, metrics]
+    middleware: [none]
+    proxy_protocol: true
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[server]
+admin_port = 9091
+unix_socket_mode = "0600"
+
+[server.route_max_body_bytes]
+"POST /api/v1/echo" = 4096
+
+[server.route_content_types]
+"POST /api/v1/echo" = ["application/json", "text/*"]
+
+[compression]
+enabled = false
+min_size = 512
+encodings = ["gzip"]
+request_decompression = false
+
+[cors]
+allowed_origins = ["https://app.example.com"]
+allow_credentials = true
+max_age = 60
+
+[request_id]
+header = "X-Correlation-ID"
+format = "ulid"
+
+[[listeners]]
+name = "internal"
+addr = "127.0.0.1:8081"
+routes = ["health", "metrics"]
+middleware = ["none"]
+proxy_protocol = true
+`,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{
+  "server": {
+    "admin_port": 9091,
+    "unix_socket_mode": "0600",
+    "route_max_body_bytes": {"POST /api/v1/echo": 4096},
+    "route_content_types": {"POST /api/v1/echo": ["application/json", "text/*"]}
+  },
+  "compression": {"enabled": false, "min_size": 512, "encodings": ["gzip"], "request_decompression": false},
+  "cors": {"allowed_origins": ["https://app.example.com"], "allow_credentials": true, "max_age": 60},
+  "request_id": {"header": "X-Correlation-ID", "format": "ulid"},
+  "listeners": [
+    {"name": "internal", "addr": "127.0.0.1:8081", "routes": ["health", "metrics"], "middleware": ["none"], "proxy_protocol": true}
+  ]
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			cfg, err := Load(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.AdminPort != "9091" {
+				t.Errorf("AdminPort = %q, want %q", cfg.AdminPort, "9091")
+			}
+			if cfg.UnixSocketMode != 0o600 {
+				t.Errorf("UnixSocketMode = %o, want 600", cfg.UnixSocketMode)
+			}
+			if got := cfg.RouteMaxBodyBytes["POST /api/v1/echo"]; got != 4096 {
+				t.Errorf("RouteMaxBodyBytes = %v, want 4096 for POST /api/v1/echo", cfg.RouteMaxBodyBytes)
+			}
+			if got := cfg.RouteContentTypes["POST /api/v1/echo"]; !slices.Equal(got, []string{"application/json", "text/*"}) {
+				t.Errorf("RouteContentTypes = %v, want [application/json text/*] for POST /api/v1/echo", cfg.RouteContentTypes)
+			}
+			if cfg.CompressionEnabled || cfg.CompressionMinSize != 512 || !slices.Equal(cfg.CompressionEncodings, []string{"gzip"}) || cfg.RequestDecompression {
+				t.Errorf("compression = %v/%d/%v/%v, want false/512/[gzip]/false",
+					cfg.CompressionEnabled, cfg.CompressionMinSize, cfg.CompressionEncodings, cfg.RequestDecompression)
+			}
+			if !slices.Equal(cfg.CORSAllowedOrigins, []string{"https://app.example.com"}) || !cfg.CORSAllowCredentials || cfg.CORSMaxAge != 60 {
+				t.Errorf("cors = %v/%v/%d, want [https://app.example.com]/true/60", cfg.CORSAllowedOrigins, cfg.CORSAllowCredentials, cfg.CORSMaxAge)
+			}
+			if cfg.RequestIDHeader != "X-Correlation-ID" || cfg.RequestIDFormat != "ulid" {
+				t.Errorf("request ID = %q/%q, want X-Correlation-ID/ulid", cfg.RequestIDHeader, cfg.RequestIDFormat)
+			}
+			want := []ListenerConfig{{
+				Name:          "internal",
+				Addr:          "127.0.0.1:8081",
+				Routes:        []string{"health", "metrics"},
+				Middleware:    []string{"none"},
+				ProxyProtocol: true,
+			}}
+			if !slices.EqualFunc(cfg.Listeners, want, equalListener) {
+				t.Errorf("Listeners = %+v, want %+v", cfg.Listeners, want)
+			}
+			if got := cfg.Source("LISTENER_INTERNAL_ADDR"); got != SourceFile {
+				t.Errorf("Source(LISTENER_INTERNAL_ADDR) = %q, want %q", got, SourceFile)
+			}
+
+			// Keys absent from the file keep their defaults
+			if !slices.Equal(cfg.CORSAllowedMethods, []string{"GET", "POST", "HEAD"}) {
+				t.Errorf("CORSAllowedMethods = %v, want the defaults", cfg.CORSAllowedMethods)
+			}
+		})
+	}
+}
+
+// equalListener compares two listener configs field by field
+func equalListener(a, b ListenerConfig) bool {
+	return a.Name == b.Name && a.Addr == b.Addr &&
+		slices.Equal(a.Routes, b.Routes) && slices.Equal(a.Middleware, b.Middleware) &&
+		a.TLSCertFile == b.TLSCertFile && a.TLSKeyFile == b.TLSKeyFile && a.ProxyProtocol == b.ProxyProtocol
+}
+
+func TestLoad_ListenerAndRoutePrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  route_max_body_bytes:
+    POST /api/v1/echo: 4096
+listeners:
+  - name: file
+    addr: :9000
+`)
+
+	clearEnv(t)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Listeners) != 1 || cfg.Listeners[0].Name != "file" {
+		t.Errorf("Listeners = %+v, want the file listener", cfg.Listeners)
+	}
+	if cfg.RouteMaxBodyBytes["POST /api/v1/echo"] != 4096 {
+		t.Errorf("RouteMaxBodyBytes = %v, want the file limits", cfg.RouteMaxBodyBytes)
+	}
+
+	// The environment replaces the file's listeners and route limits rather than merging them
+	t.Setenv("LISTENERS", "env")
+	t.Setenv("LISTENER_ENV_ADDR", ":9100")
+	t.Setenv("ROUTE_MAX_BODY_BYTES", "POST /api/v1/echo=128")
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Listeners) != 1 || cfg.Listeners[0].Name != "env" {
+		t.Errorf("Listeners = %+v, want only the env listener", cfg.Listeners)
+	}
+	if cfg.RouteMaxBodyBytes["POST /api/v1/echo"] != 128 {
+		t.Errorf("RouteMaxBodyBytes = %v, want the env limits", cfg.RouteMaxBodyBytes)
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "api_keys")
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(keysFile, []byte("# keys\nkey-one\nkey-two\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(tokenFile, []byte("scrape-token\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		wantKeys    int
+		wantToken   string
+		wantMessage string
+	}{
+		{
+			name:      "secrets read from files",
+			content:   "auth:\n  api_keys_file: " + keysFile + "\nmetrics:\n  token_file: " + tokenFile + "\n",
+			wantKeys:  2,
+			wantToken: "scrape-token",
+		},
+		{
+			name:        "inline and file keys",
+			content:     "auth:\n  api_keys: [inline]\n  api_keys_file: " + keysFile + "\n",
+			wantMessage: "auth.api_keys, auth.api_keys_file: set only one of them",
+		},
+		{
+			name:        "missing secret file",
+			content:     "metrics:\n  password_file: " + filepath.Join(dir, "missing") + "\n",
+			wantMessage: "metrics.password_file: open ",
+		},
+		{
+			name:        "several values for one secret",
+			content:     "metrics:\n  token_file: " + keysFile + "\n",
+			wantMessage: "metrics.token_file: expected one value, found 2",
+		},
+		{
+			name:        "inline and file admin token",
+			content:     "server:\n  admin_token: inline\n  admin_token_file: " + tokenFile + "\n",
+			wantMessage: "server.admin_token, server.admin_token_file: set only one of them",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+
+			cfg, err := Load(writeConfigFile(t, "config.yaml", tt.content))
+			if tt.wantMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Errorf("Load() error = %v, want it to contain %q", err, tt.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.APIKeyCount() != tt.wantKeys {
+				t.Errorf("APIKeyCount() = %d, want %d", cfg.APIKeyCount(), tt.wantKeys)
+			}
+			if cfg.MetricsToken != tt.wantToken {
+				t.Errorf("MetricsToken = %q, want %q", cfg.MetricsToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	clearEnv(t)
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces secret values in Settings
+const Redacted = "[REDACTED]"
+
+// Setting is one effective configuration value, named by its environment variable
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Settings returns every effective setting in environment variable syntax, sorted by key
+// Secrets are replaced with Redacted, API keys are reduced to their count
+func (c *Config) Settings() []Setting {
+	settings := []Setting{
+		{"PORT", c.Port},
+		{"LOG_LEVEL", c.LogLevel},
+		{"LOG_FORMAT", c.LogFormat},
+		{"LOG_OUTPUT", c.LogOutput},
+		{"LOG_MAX_SIZE", strconv.FormatInt(c.LogMaxSize, 10)},
+		{"LOG_MAX_BACKUPS", strconv.Itoa(c.LogMaxBackups)},
+		{"LOG_SOURCE", strconv.FormatBool(c.LogSource)},
+		{"LOG_SERVICE", c.LogService},
+		{"LOG_ENVIRONMENT", c.LogEnvironment},
+		{"LOG_INSTANCE_ID", c.LogInstanceID},
+		{"ADMIN_PORT", c.AdminPort},
+		{"ADMIN_TOKEN", redact(c.AdminToken)},
+		{"LISTEN_ADDR", c.ListenAddr},
+		{"UNIX_SOCKET_MODE", fmt.Sprintf("%04o", uint32(c.UnixSocketMode))},
+		{"PROXY_PROTOCOL", strconv.FormatBool(c.ProxyProtocol)},
+		{"TRUSTED_PROXIES", joinStrings(c.TrustedProxies)},
+
+		{"AUTH_ENABLED", strconv.FormatBool(c.AuthEnabled)},
+		{"API_KEYS", redactKeys(c.APIKeyCount())},
+
+		{"READ_TIMEOUT", c.ReadTimeout.String()},
+		{"READ_HEADER_TIMEOUT", c.ReadHeaderTimeout.String()},
+		{"WRITE_TIMEOUT", c.WriteTimeout.String()},
+		{"IDLE_TIMEOUT", c.IdleTimeout.String()},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout.String()},
+		{"SHUTDOWN_DRAIN_DELAY", c.ShutdownDrainDelay.String()},
+		{"MAX_HEADER_BYTES", strconv.Itoa(c.MaxHeaderBytes)},
+
+		{"TLS_CERT_FILE", c.TLSCertFile},
+		{"TLS_KEY_FILE", c.TLSKeyFile},
+		{"HTTP3_ENABLED", strconv.FormatBool(c.HTTP3Enabled)},
+		{"HTTP3_ADDR", c.HTTP3Addr},
+		{"H2C_ENABLED", strconv.FormatBool(c.H2CEnabled)},
+		{"HTTP2_MAX_CONCURRENT_STREAMS", strconv.Itoa(c.HTTP2MaxConcurrentStreams)},
+		{"HTTP2_MAX_READ_FRAME_SIZE", strconv.Itoa(c.HTTP2MaxReadFrameSize)},
+
+		{"MOCK_ROUTES_FILE", c.MockRoutesFile},
+		{"MOCK_ROUTES_RELOAD_INTERVAL", c.MockRoutesReloadInterval.String()},
+
+		{"MAX_BODY_BYTES", strconv.FormatInt(c.MaxBodyBytes, 10)},
+		{"ROUTE_MAX_BODY_BYTES", formatMap(c.RouteMaxBodyBytes, func(n int64) string { return strconv.FormatInt(n, 10) })},
+		{"ROUTE_CONTENT_TYPES", formatMap(c.RouteContentTypes, func(l []string) string { return strings.Join(l, "|") })},
+
+		{"COMPRESSION_ENABLED", strconv.FormatBool(c.CompressionEnabled)},
+		{"COMPRESSION_MIN_SIZE", strconv.Itoa(c.CompressionMinSize)},
+		{"COMPRESSION_CONTENT_TYPES", strings.Join(c.CompressionContentTypes, ",")},
+		{"COMPRESSION_ENCODINGS", strings.Join(c.CompressionEncodings, ",")},
+		{"REQUEST_DECOMPRESSION", strconv.FormatBool(c.RequestDecompression)},
+
+		{"CORS_ALLOWED_ORIGINS", strings.Join(c.CORSAllowedOrigins, ",")},
+		{"CORS_ALLOWED_METHODS", strings.Join(c.CORSAllowedMethods, ",")},
+		{"CORS_ALLOWED_HEADERS", strings.Join(c.CORSAllowedHeaders, ",")},
+		{"CORS_EXPOSED_HEADERS", strings.Join(c.CORSExposedHeaders, ",")},
+		{"CORS_ALLOW_CREDENTIALS", strconv.FormatBool(c.CORSAllowCredentials)},
+		{"CORS_MAX_AGE", strconv.Itoa(c.CORSMaxAge)},
+
+		{"REQUEST_ID_HEADER", c.RequestIDHeader},
+		{"REQUEST_ID_FORMAT", c.RequestIDFormat},
+
+		{"ACCESS_LOG_ENABLED", strconv.FormatBool(c.AccessLogEnabled)},
+		{"ACCESS_LOG_SAMPLE_RATE", formatFloat(c.AccessLogSampleRate)},
+		{"ACCESS_LOG_FIELDS", strings.Join(c.AccessLogFields, ",")},
+		{"ACCESS_LOG_FORMAT", c.AccessLogFormat},
+		{"ACCESS_LOG_FILE", c.AccessLogFile},
+
+		{"METRICS_ENABLED", strconv.FormatBool(!c.MetricsDisabled)},
+		{"METRICS_TOKEN", redact(c.MetricsToken)},
+		{"METRICS_USERNAME", c.MetricsUsername},
+		{"METRICS_PASSWORD", redact(c.MetricsPassword)},
+		{"METRICS_BUCKETS", joinFloats(c.MetricsBuckets)},
+		{"METRICS_NATIVE_HISTOGRAMS", strconv.FormatBool(c.MetricsNativeHistograms)},
+		{"METRICS_NATIVE_BUCKET_FACTOR", formatFloat(c.MetricsNativeBucketFactor)},
+		{"METRICS_NATIVE_MAX_BUCKETS", strconv.FormatUint(uint64(c.MetricsNativeMaxBuckets), 10)},
+	}
+
+	names := make([]string, 0, len(c.Listeners))
+	for _, l := range c.Listeners {
+		names = append(names, l.Name)
+		prefix := "LISTENER_" + envName(l.Name) + "_"
+		settings = append(settings,
+			Setting{prefix + "ADDR", l.Addr},
+			Setting{prefix + "ROUTES", strings.Join(l.Routes, ",")},
+			Setting{prefix + "MIDDLEWARE", strings.Join(l.Middleware, ",")},
+			Setting{prefix + "TLS_CERT", l.TLSCertFile},
+			Setting{prefix + "TLS_KEY", l.TLSKeyFile},
+			Setting{prefix + "PROXY_PROTOCOL", strconv.FormatBool(l.ProxyProtocol)},
+		)
+	}
+	settings = append(settings, Setting{"LISTENERS", strings.Join(names, ",")})
+
+	slices.SortFunc(settings, func(a, b Setting) int { return strings.Compare(a.Key, b.Key) })
+	return settings
+}
+
+// redact hides a secret, leaving unset secrets empty so they are visibly unset
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// redactKeys describes the configured API keys by count only
+func redactKeys(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s (%d keys)", Redacted, count)
+}
+
+// formatFloat formats f in its shortest exact form
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// joinFloats formats a list of floats as a comma-separated list
+func joinFloats(floats []float64) string {
+	parts := make([]string, len(floats))
+	for i, f := range floats {
+		parts[i] = formatFloat(f)
+	}
+	return strings.Join(parts, ",")
+}
+
+// joinStrings formats a list of values with a String method as a comma-separated list
+func joinStrings[T fmt.Stringer](values []T) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatMap formats m as comma-separated key=value pairs sorted by key
+func formatMap[V any](m map[string]V, format func(V) string) string {
+	parts := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		parts = append(parts, k+"="+format(m[k]))
+	}
+	return strings.Join(parts, ",")
+}
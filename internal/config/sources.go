@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"reflect"
+)
+
+// Sources of a setting, reported by Config.Source
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Source reports which layer set the setting named by its environment variable, as in Settings
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// SetSource records that the setting named by key was set by source, such as a command line flag
+func (c *Config) SetSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// markEnvSources records the settings whose environment variable, or its _FILE form, is set
+func (c *Config) markEnvSources() {
+	for _, s := range c.Settings() {
+		_, exists := os.LookupEnv(s.Key)
+		_, fileExists := os.LookupEnv(s.Key + "_FILE")
+		if exists || fileExists {
+			c.SetSource(s.Key, SourceEnv)
+		}
+	}
+}
+
+// keys returns the environment variable names of the settings present in the file
+func (f *FileConfig) keys() []string {
+	var keys []string
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := range v.NumField() {
+			field, value := v.Type().Field(i), v.Field(i)
+			switch {
+			case field.Type.Kind() == reflect.Struct:
+				walk(value)
+			case !value.IsNil():
+				keys = append(keys, field.Tag.Get("env"))
+			}
+		}
+	}
+	walk(reflect.ValueOf(f).Elem())
+
+	for _, l := range f.Listeners {
+		prefix := "LISTENER_" + envName(l.Name) + "_"
+		for _, suffix := range []string{"ADDR", "ROUTES", "MIDDLEWARE", "TLS_CERT", "TLS_KEY", "PROXY_PROTOCOL"} {
+			keys = append(keys, prefix+suffix)
+		}
+	}
+	return keys
+}
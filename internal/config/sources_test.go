@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestConfig_Source(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secret, []byte("operator\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	clearEnv(t)
+	t.Setenv("PORT", "7070")
+	t.Setenv("ADMIN_TOKEN_FILE", secret)
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: "9090"
+  read_timeout: 30s
+auth:
+  api_keys_file: `+secret+`
+logging:
+  access_log:
+    format: common
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	cfg.SetSource("LOG_LEVEL", SourceFlag)
+
+	for key, want := range map[string]string{
+		"PORT":              SourceEnv,
+		"ADMIN_TOKEN":       SourceEnv,
+		"READ_TIMEOUT":      SourceFile,
+		"API_KEYS":          SourceFile,
+		"ACCESS_LOG_FORMAT": SourceFile,
+		"LOG_LEVEL":         SourceFlag,
+		"WRITE_TIMEOUT":     SourceDefault,
+	} {
+		if got := cfg.Source(key); got != want {
+			t.Errorf("Source(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	// Configs built without Load report every setting as a default
+	if got := (&Config{}).Source("PORT"); got != SourceDefault {
+		t.Errorf("Source(PORT) on a literal Config = %q, want %q", got, SourceDefault)
+	}
+}
+
+func TestFileConfig_EnvTags(t *testing.T) {
+	var keys []string
+	for _, s := range defaults().Settings() {
+		keys = append(keys, s.Key)
+	}
+
+	var walk func(typ reflect.Type, path string)
+	walk = func(typ reflect.Type, path string) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			name := path + field.Tag.Get("config")
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, name+".")
+				continue
+			}
+			if env := field.Tag.Get("env"); !slices.Contains(keys, env) {
+				t.Errorf("%s has env tag %q, want the name of a setting", name, env)
+			}
+		}
+	}
+	walk(reflect.TypeFor[FileConfig](), "")
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"mime"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error formats the problems as an indented list, one per line
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// RouteGroups are the route group names accepted in a listener's routes
+var RouteGroups = []string{"api", "health", "metrics", "admin", "mock"}
+
+// ListenerMiddleware are the optional middleware names accepted in a listener's middleware, none selects none of them
+var ListenerMiddleware = []string{"metrics", "compression", "bodylimit", "auth", "cors", "accesslog", "none"}
+
+// compressionEncodings are the supported response encodings
+var compressionEncodings = []string{"zstd", "br", "gzip"}
+
+// envCheck validates the raw value of an environment variable
+type envCheck func(value string) error
+
+// envChecks lists the typed environment variables whose getEnv helpers fall back to the default on invalid input
+// Checking them separately lets Validate report the typo instead of silently running with the default
+var envChecks = map[string]envCheck{
+	"AUTH_ENABLED":           discard(parseBool),
+	"PROXY_PROTOCOL":         discard(parseBool),
+	"H2C_ENABLED":            discard(parseBool),
+	"HTTP3_ENABLED":          discard(parseBool),
+	"COMPRESSION_ENABLED":    discard(parseBool),
+	"REQUEST_DECOMPRESSION":  discard(parseBool),
+	"CORS_ALLOW_CREDENTIALS": discard(parseBool),
+	"ACCESS_LOG_ENABLED":     discard(parseBool),
+	"LOG_SOURCE":             discard(parseBool),
+	"METRICS_ENABLED":        discard(parseBool),
+
+	"METRICS_NATIVE_HISTOGRAMS": discard(parseBool),
+	"READ_TIMEOUT":              discard(parsePositiveDuration),
+	"READ_HEADER_TIMEOUT":       discard(parsePositiveDuration),
+	"WRITE_TIMEOUT":             discard(parsePositiveDuration),
+	"IDLE_TIMEOUT":              discard(parsePositiveDuration),
+	"SHUTDOWN_TIMEOUT":          discard(parsePositiveDuration),
+	"SHUTDOWN_DRAIN_DELAY":      discard(parseDuration),
+
+	"MOCK_ROUTES_RELOAD_INTERVAL": discard(parseDuration),
+
+	"MAX_HEADER_BYTES":             discard(parseInt),
+	"MAX_BODY_BYTES":               discard(parseInt),
+	"HTTP2_MAX_CONCURRENT_STREAMS": discard(parseInt),
+	"HTTP2_MAX_READ_FRAME_SIZE":    discard(parseInt),
+	"COMPRESSION_MIN_SIZE":         discard(parseInt),
+	"CORS_MAX_AGE":                 discard(parseInt),
+	"METRICS_NATIVE_MAX_BUCKETS":   discard(parseInt),
+	"LOG_MAX_SIZE":                 discard(parseInt),
+	"LOG_MAX_BACKUPS":              discard(parseInt),
+
+	"ACCESS_LOG_SAMPLE_RATE":       discard(parseFloat),
+	"METRICS_NATIVE_BUCKET_FACTOR": discard(parseFloat),
+	"METRICS_BUCKETS":              discard(parseFloats),
+	"UNIX_SOCKET_MODE":             discard(parseFileMode),
+	"TRUSTED_PROXIES": func(value string) error {
+		_, err := parsePrefixes(splitList(value))
+		return err
+	},
+	"ROUTE_MAX_BODY_BYTES": func(value string) error {
+		for _, part := range strings.Split(value, ",") {
+			route, limit, ok := strings.Cut(part, "=")
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			if !ok || strings.TrimSpace(route) == "" {
+				return fmt.Errorf("invalid entry %q, use \"METHOD /path=bytes\"", strings.TrimSpace(part))
+			}
+			if _, err := parseInt(limit); err != nil {
+				return fmt.Errorf("%s: %w", strings.TrimSpace(route), err)
+			}
+		}
+		return nil
+	},
+	"ROUTE_CONTENT_TYPES": func(value string) error {
+		for _, part := range strings.Split(value, ",") {
+			route, _, ok := strings.Cut(part, "=")
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			if !ok || strings.TrimSpace(route) == "" {
+				return fmt.Errorf("invalid entry %q, use \"METHOD /path=type|type\"", strings.TrimSpace(part))
+			}
+		}
+		return nil
+	},
+}
+
+// checkMediaType validates an accepted content type such as application/json or text/*
+func checkMediaType(value string) error {
+	if prefix, ok := strings.CutSuffix(value, "/*"); ok && prefix != "" && !strings.ContainsAny(prefix, "/*") {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return fmt.Errorf("invalid media type %q, use a type such as application/json or text/*", value)
+	}
+	return nil
+}
+
+// discard adapts a parse function into an envCheck
+func discard[T any](parse func(string) (T, error)) envCheck {
+	return func(value string) error {
+		_, err := parse(value)
+		return err
+	}
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, part := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			list = append(list, trimmed)
+		}
+	}
+	return list
+}
+
+// checkEnv records malformed environment variables so Validate can report them
+func (c *Config) checkEnv() {
+	c.envProblems = nil
+	for _, key := range slices.Sorted(maps.Keys(envChecks)) {
+		value, exists := os.LookupEnv(key)
+		if !exists {
+			continue
+		}
+		if err := envChecks[key](value); err != nil {
+			c.envProblems = append(c.envProblems, key+": "+err.Error())
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range splitList(getEnv("LISTENERS", "")) {
+		prefix := "LISTENER_" + envName(name) + "_"
+		switch {
+		case name == "main" || name == "admin":
+			c.envProblems = append(c.envProblems, fmt.Sprintf("LISTENERS: listener name %q is reserved", name))
+		case seen[name]:
+			c.envProblems = append(c.envProblems, fmt.Sprintf("LISTENERS: duplicate listener %q", name))
+		case strings.TrimSpace(getEnv(prefix+"ADDR", "")) == "":
+			c.envProblems = append(c.envProblems, fmt.Sprintf("%sADDR: required for listener %q", prefix, name))
+		}
+		seen[name] = true
+
+		if value, exists := os.LookupEnv(prefix + "PROXY_PROTOCOL"); exists {
+			if _, err := parseBool(value); err != nil {
+				c.envProblems = append(c.envProblems, prefix+"PROXY_PROTOCOL: "+err.Error())
+			}
+		}
+	}
+}
+
+// Validate checks the configuration and returns a *ValidationError listing every problem, or nil
+func (c *Config) Validate() error {
+	problems := slices.Clone(c.envProblems)
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.ListenAddr == "" && !validPort(c.Port) {
+		add("PORT: invalid port %q, use a number between 0 and 65535", c.Port)
+	}
+	if c.AdminPort != "" && !validPort(c.AdminPort) {
+		add("ADMIN_PORT: invalid port %q, use a number between 0 and 65535", c.AdminPort)
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, c.LogLevel) {
+		add("LOG_LEVEL: unknown level %q, use debug, info, warn or error", c.LogLevel)
+	}
+	if !slices.Contains([]string{"json", "logfmt", "text"}, c.LogFormat) {
+		add("LOG_FORMAT: unknown format %q, use json, logfmt or text", c.LogFormat)
+	}
+	if c.LogOutput == "" {
+		add("LOG_OUTPUT: use stdout, stderr or a file path")
+	}
+	if c.AuthEnabled && !c.HasAPIKeys() {
+		add("AUTH_ENABLED: authentication is enabled but API_KEYS is empty")
+	}
+
+	// Zero timeouts can only come from a config file, invalid environment values were reported above
+	for key, d := range map[string]int64{
+		"READ_TIMEOUT":     int64(c.ReadTimeout),
+		"WRITE_TIMEOUT":    int64(c.WriteTimeout),
+		"IDLE_TIMEOUT":     int64(c.IdleTimeout),
+		"SHUTDOWN_TIMEOUT": int64(c.ShutdownTimeout),
+	} {
+		if d <= 0 {
+			add("%s: must be greater than zero", key)
+		}
+	}
+	if c.MaxHeaderBytes <= 0 {
+		add("MAX_HEADER_BYTES: must be greater than zero")
+	}
+	if c.HTTP2MaxConcurrentStreams <= 0 {
+		add("HTTP2_MAX_CONCURRENT_STREAMS: must be greater than zero")
+	}
+	if c.HTTP2MaxReadFrameSize < minHTTP2FrameSize || c.HTTP2MaxReadFrameSize > maxHTTP2FrameSize {
+		add("HTTP2_MAX_READ_FRAME_SIZE: %d is outside %d to %d", c.HTTP2MaxReadFrameSize, minHTTP2FrameSize, maxHTTP2FrameSize)
+	}
+	if c.ShutdownDrainDelay > 0 && c.ShutdownTimeout > 0 && c.ShutdownDrainDelay >= c.ShutdownTimeout {
+		add("SHUTDOWN_DRAIN_DELAY: %v must be shorter than SHUTDOWN_TIMEOUT (%v)", c.ShutdownDrainDelay, c.ShutdownTimeout)
+	}
+
+	if (c.MetricsUsername == "") != (c.MetricsPassword == "") {
+		add("METRICS_USERNAME, METRICS_PASSWORD: both must be set to use basic auth")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		add("TLS_CERT_FILE, TLS_KEY_FILE: both must be set to serve TLS")
+	}
+	if c.HTTP3Enabled && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		add("HTTP3_ENABLED: HTTP/3 requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	// A PROXY header sets the client address, so over TCP only the listed load balancers may send one
+	if c.ProxyProtocol && len(c.TrustedProxies) == 0 && !strings.HasPrefix(c.Address(), "unix:") {
+		add("PROXY_PROTOCOL: TRUSTED_PROXIES must list the load balancers allowed to send PROXY headers")
+	}
+	for _, l := range c.Listeners {
+		prefix := "LISTENER_" + envName(l.Name) + "_"
+		if l.ProxyProtocol && len(c.TrustedProxies) == 0 && !strings.HasPrefix(l.Addr, "unix:") {
+			add("%sPROXY_PROTOCOL: TRUSTED_PROXIES must list the load balancers allowed to send PROXY headers", prefix)
+		}
+		if (l.TLSCertFile == "") != (l.TLSKeyFile == "") {
+			add("%sTLS_CERT, %sTLS_KEY: both must be set to serve TLS", prefix, prefix)
+		}
+		for _, group := range l.Routes {
+			if !slices.Contains(RouteGroups, group) {
+				add("%sROUTES: unknown route group %q, use %s", prefix, group, orList(RouteGroups))
+			}
+		}
+		for _, name := range l.Middleware {
+			if !slices.Contains(ListenerMiddleware, name) {
+				add("%sMIDDLEWARE: unknown middleware %q, use %s", prefix, name, orList(ListenerMiddleware))
+			}
+		}
+	}
+
+	for _, enc := range c.CompressionEncodings {
+		if !slices.Contains(compressionEncodings, enc) {
+			add("COMPRESSION_ENCODINGS: unknown encoding %q, use %s", enc, orList(compressionEncodings))
+		}
+	}
+	for route, types := range c.RouteContentTypes {
+		if len(types) == 0 {
+			add("ROUTE_CONTENT_TYPES: %s: list at least one content type", route)
+		}
+		for _, t := range types {
+			if err := checkMediaType(t); err != nil {
+				add("ROUTE_CONTENT_TYPES: %s: %v", route, err)
+			}
+		}
+	}
+
+	if !slices.Contains([]string{"json", "common", "combined"}, c.AccessLogFormat) {
+		add("ACCESS_LOG_FORMAT: unknown format %q, use json, common or combined", c.AccessLogFormat)
+	}
+	// Echoing every origin with credentials would let any site make authenticated requests, browsers refuse "*" for the same reason
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		add("CORS_ALLOW_CREDENTIALS: cannot be used with CORS_ALLOWED_ORIGINS=*, list the allowed origins instead")
+	}
+	if c.AccessLogFile != "" && c.AccessLogFormat == "json" {
+		add("ACCESS_LOG_FILE: only used by the common and combined formats, json access logs go to LOG_OUTPUT")
+	}
+	if c.AccessLogSampleRate < 0 || c.AccessLogSampleRate > 1 {
+		add("ACCESS_LOG_SAMPLE_RATE: %v is outside 0 to 1", c.AccessLogSampleRate)
+	}
+	if !slices.Contains([]string{"uuidv7", "ulid"}, c.RequestIDFormat) {
+		add("REQUEST_ID_FORMAT: unknown format %q, use uuidv7 or ulid", c.RequestIDFormat)
+	}
+	if c.MetricsNativeHistograms && c.MetricsNativeBucketFactor <= 1 {
+		add("METRICS_NATIVE_BUCKET_FACTOR: %v must be greater than 1", c.MetricsNativeBucketFactor)
+	}
+	if !slices.IsSorted(c.MetricsBuckets) {
+		add("METRICS_BUCKETS: buckets must be in increasing order")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return &ValidationError{Problems: slices.Compact(problems)}
+}
+
+// orList formats values as "a, b or c" for problem messages
+func orList(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+// validPort reports whether port is a TCP port number, 0 picks a free port
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
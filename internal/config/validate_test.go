@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		file         string
+		wantProblems []string
+	}{
+		{name: "defaults are valid"},
+		{
+			name: "valid custom values",
+			env: map[string]string{
+				"PORT":          "0",
+				"ADMIN_PORT":    "9090",
+				"LOG_LEVEL":     "debug",
+				"AUTH_ENABLED":  "true",
+				"API_KEYS":      "key1",
+				"READ_TIMEOUT":  "30s",
+				"TLS_CERT_FILE": "cert.pem",
+				"TLS_KEY_FILE":  "key.pem",
+				"HTTP3_ENABLED": "on",
+			},
+		},
+		{
+			name: "listen address replaces the port",
+			env:  map[string]string{"PORT": "not-a-port", "LISTEN_ADDR": "unix:/run/echo.sock"},
+		},
+		{
+			name: "invalid port and log level",
+			env:  map[string]string{"PORT": "80800", "LOG_LEVEL": "verbose", "ADMIN_PORT": "admin"},
+			wantProblems: []string{
+				`ADMIN_PORT: invalid port "admin", use a number between 0 and 65535`,
+				`LOG_LEVEL: unknown level "verbose", use debug, info, warn or error`,
+				`PORT: invalid port "80800", use a number between 0 and 65535`,
+			},
+		},
+		{
+			name:         "auth enabled without keys",
+			env:          map[string]string{"AUTH_ENABLED": "true"},
+			wantProblems: []string{"AUTH_ENABLED: authentication is enabled but API_KEYS is empty"},
+		},
+		{
+			name: "malformed values that would fall back to defaults",
+			env: map[string]string{
+				"AUTH_ENABLED":    "ture",
+				"READ_TIMEOUT":    "fast",
+				"WRITE_TIMEOUT":   "0s",
+				"MAX_BODY_BYTES":  "-1",
+				"METRICS_BUCKETS": "0.1,slow",
+				"TRUSTED_PROXIES": "10.0.0.0/8,proxy",
+			},
+			wantProblems: []string{
+				`AUTH_ENABLED: invalid boolean "ture", use true or false`,
+				`MAX_BODY_BYTES: invalid value "-1", use a non-negative integer`,
+				`METRICS_BUCKETS: invalid number "slow"`,
+				`READ_TIMEOUT: invalid duration "fast", use a value such as 500ms, 15s or 5m`,
+				`TRUSTED_PROXIES: invalid CIDR or IP "proxy"`,
+				`WRITE_TIMEOUT: duration "0s" must be greater than zero`,
+			},
+		},
+		{
+			name: "listener problems",
+			env: map[string]string{
+				"LISTENERS":                    "ops,admin,tls-public",
+				"LISTENER_OPS_PROXY_PROTOCOL":  "maybe",
+				"LISTENER_TLS_PUBLIC_ADDR":     ":8443",
+				"LISTENER_TLS_PUBLIC_TLS_CERT": "cert.pem",
+			},
+			wantProblems: []string{
+				`LISTENERS: listener name "admin" is reserved`,
+				`LISTENER_OPS_ADDR: required for listener "ops"`,
+				`LISTENER_OPS_PROXY_PROTOCOL: invalid boolean "maybe", use true or false`,
+				"LISTENER_TLS_PUBLIC_TLS_CERT, LISTENER_TLS_PUBLIC_TLS_KEY: both must be set to serve TLS",
+			},
+		},
+		{
+			name: "proxy protocol without trusted proxies",
+			env: map[string]string{
+				"PROXY_PROTOCOL":                "true",
+				"LISTENERS":                     "lb,local",
+				"LISTENER_LB_ADDR":              ":8443",
+				"LISTENER_LB_PROXY_PROTOCOL":    "true",
+				"LISTENER_LOCAL_ADDR":           "unix:/run/echo.sock",
+				"LISTENER_LOCAL_PROXY_PROTOCOL": "true",
+			},
+			wantProblems: []string{
+				"LISTENER_LB_PROXY_PROTOCOL: TRUSTED_PROXIES must list the load balancers allowed to send PROXY headers",
+				"PROXY_PROTOCOL: TRUSTED_PROXIES must list the load balancers allowed to send PROXY headers",
+			},
+		},
+		{
+			name: "proxy protocol with trusted proxies",
+			env:  map[string]string{"PROXY_PROTOCOL": "true", "TRUSTED_PROXIES": "10.0.0.0/8"},
+		},
+		{
+			name:         "metrics username without password",
+			env:          map[string]string{"METRICS_USERNAME": "prom"},
+			wantProblems: []string{"METRICS_USERNAME, METRICS_PASSWORD: both must be set to use basic auth"},
+		},
+		{
+			name:         "metrics password without username",
+			env:          map[string]string{"METRICS_PASSWORD": "secret"},
+			wantProblems: []string{"METRICS_USERNAME, METRICS_PASSWORD: both must be set to use basic auth"},
+		},
+		{
+			name: "TLS and HTTP/3",
+			env:  map[string]string{"TLS_KEY_FILE": "key.pem", "HTTP3_ENABLED": "true"},
+			wantProblems: []string{
+				"HTTP3_ENABLED: HTTP/3 requires TLS_CERT_FILE and TLS_KEY_FILE",
+				"TLS_CERT_FILE, TLS_KEY_FILE: both must be set to serve TLS",
+			},
+		},
+		{
+			name: "unknown formats and ranges",
+			env: map[string]string{
+				"ACCESS_LOG_FORMAT":            "apache",
+				"ACCESS_LOG_SAMPLE_RATE":       "1.5",
+				"REQUEST_ID_FORMAT":            "uuidv4",
+				"METRICS_NATIVE_HISTOGRAMS":    "true",
+				"METRICS_NATIVE_BUCKET_FACTOR": "1",
+				"METRICS_BUCKETS":              "1,0.5",
+			},
+			wantProblems: []string{
+				`ACCESS_LOG_FORMAT: unknown format "apache", use json, common or combined`,
+				"ACCESS_LOG_SAMPLE_RATE: 1.5 is outside 0 to 1",
+				"METRICS_BUCKETS: buckets must be in increasing order",
+				"METRICS_NATIVE_BUCKET_FACTOR: 1 must be greater than 1",
+				`REQUEST_ID_FORMAT: unknown format "uuidv4", use uuidv7 or ulid`,
+			},
+		},
+		{
+			name: "log settings",
+			env: map[string]string{
+				"LOG_FORMAT":      "pretty",
+				"LOG_OUTPUT":      "",
+				"LOG_SOURCE":      "yes please",
+				"LOG_MAX_BACKUPS": "many",
+			},
+			wantProblems: []string{
+				`LOG_FORMAT: unknown format "pretty", use json, logfmt or text`,
+				`LOG_MAX_BACKUPS: invalid value "many", use a non-negative integer`,
+				"LOG_OUTPUT: use stdout, stderr or a file path",
+				`LOG_SOURCE: invalid boolean "yes please", use true or false`,
+			},
+		},
+		{
+			name:         "CORS credentials with any origin",
+			env:          map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com,*", "CORS_ALLOW_CREDENTIALS": "true"},
+			wantProblems: []string{"CORS_ALLOW_CREDENTIALS: cannot be used with CORS_ALLOWED_ORIGINS=*, list the allowed origins instead"},
+		},
+		{
+			name: "CORS credentials with listed origins",
+			env:  map[string]string{"CORS_ALLOWED_ORIGINS": "https://*.example.com", "CORS_ALLOW_CREDENTIALS": "true"},
+		},
+		{
+			name:         "access log file with json format",
+			env:          map[string]string{"ACCESS_LOG_FILE": "/var/log/echo/access.log", "ACCESS_LOG_FORMAT": "json"},
+			wantProblems: []string{"ACCESS_LOG_FILE: only used by the common and combined formats, json access logs go to LOG_OUTPUT"},
+		},
+		{
+			name: "access log file with common format",
+			env:  map[string]string{"ACCESS_LOG_FILE": "/var/log/echo/access.log", "ACCESS_LOG_FORMAT": "common"},
+		},
+		{
+			name:         "drain delay not shorter than shutdown timeout",
+			env:          map[string]string{"SHUTDOWN_DRAIN_DELAY": "10s", "SHUTDOWN_TIMEOUT": "10s"},
+			wantProblems: []string{"SHUTDOWN_DRAIN_DELAY: 10s must be shorter than SHUTDOWN_TIMEOUT (10s)"},
+		},
+		{
+			name: "drain delay shorter than shutdown timeout",
+			env:  map[string]string{"SHUTDOWN_DRAIN_DELAY": "5s", "SHUTDOWN_TIMEOUT": "10s"},
+		},
+		{
+			name: "server limits out of range",
+			env: map[string]string{
+				"MAX_HEADER_BYTES":             "0",
+				"HTTP2_MAX_CONCURRENT_STREAMS": "0",
+				"HTTP2_MAX_READ_FRAME_SIZE":    "1024",
+			},
+			wantProblems: []string{
+				"HTTP2_MAX_CONCURRENT_STREAMS: must be greater than zero",
+				"HTTP2_MAX_READ_FRAME_SIZE: 1024 is outside 16384 to 16777215",
+				"MAX_HEADER_BYTES: must be greater than zero",
+			},
+		},
+		{
+			name:         "frame size above maximum",
+			env:          map[string]string{"HTTP2_MAX_READ_FRAME_SIZE": "16777216"},
+			wantProblems: []string{"HTTP2_MAX_READ_FRAME_SIZE: 16777216 is outside 16384 to 16777215"},
+		},
+		{
+			name: "unknown encodings and content types",
+			env: map[string]string{
+				"COMPRESSION_ENCODINGS": "zstd,deflate",
+				"ROUTE_CONTENT_TYPES":   "POST /api/v1/echo=application/json|json",
+			},
+			wantProblems: []string{
+				`COMPRESSION_ENCODINGS: unknown encoding "deflate", use zstd, br or gzip`,
+				`ROUTE_CONTENT_TYPES: POST /api/v1/echo: invalid media type "json", use a type such as application/json or text/*`,
+			},
+		},
+		{
+			name:         "malformed route content types",
+			env:          map[string]string{"ROUTE_CONTENT_TYPES": "application/json"},
+			wantProblems: []string{`ROUTE_CONTENT_TYPES: invalid entry "application/json", use "METHOD /path=type|type"`},
+		},
+		{
+			name: "valid content types",
+			env:  map[string]string{"ROUTE_CONTENT_TYPES": "POST /api/v1/echo=application/json|text/*,PUT /upload=application/octet-stream"},
+		},
+		{
+			name: "unknown listener routes and middleware",
+			env: map[string]string{
+				"LISTENERS":               "ops",
+				"LISTENER_OPS_ADDR":       ":9091",
+				"LISTENER_OPS_ROUTES":     "health,status",
+				"LISTENER_OPS_MIDDLEWARE": "auth,gzip",
+			},
+			wantProblems: []string{
+				`LISTENER_OPS_MIDDLEWARE: unknown middleware "gzip", use metrics, compression, bodylimit, auth, cors, accesslog or none`,
+				`LISTENER_OPS_ROUTES: unknown route group "status", use api, health, metrics, admin or mock`,
+			},
+		},
+		{
+			name:         "zero timeout from a file",
+			file:         "server:\n  idle_timeout: 0s\n",
+			wantProblems: []string{"IDLE_TIMEOUT: must be greater than zero"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var path string
+			if tt.file != "" {
+				path = writeConfigFile(t, "config.yaml", tt.file)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			err = cfg.Validate()
+			if tt.wantProblems == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+			}
+			if !slices.Equal(verr.Problems, tt.wantProblems) {
+				t.Errorf("Validate() problems =\n%s\nwant\n%s", strings.Join(verr.Problems, "\n"), strings.Join(tt.wantProblems, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	err := &ValidationError{Problems: []string{"LOG_LEVEL: unknown level", "PORT: invalid port"}}
+
+	want := "invalid configuration:\n  - LOG_LEVEL: unknown level\n  - PORT: invalid port"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -2,35 +2,164 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/health"
+	"github.com/lkendrickd/echo-server/internal/middleware"
 )
 
-// errorResponse represents a JSON error response
-type errorResponse struct {
-	Error string `json:"error"`
-}
+// ProtocolHeader reports the negotiated protocol, such as HTTP/1.1 or HTTP/2.0, on echo responses
+const ProtocolHeader = "X-Echo-Protocol"
 
 // EchoHandler is the echo handler that returns the request body
 func EchoHandler(w http.ResponseWriter, r *http.Request) {
 	// Read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+		// Bodies cut off by http.MaxBytesReader are the client's fault
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			middleware.WriteError(w, http.StatusRequestEntityTooLarge, "request body exceeds "+strconv.FormatInt(maxErr.Limit, 10)+" bytes")
+			return
+		}
+		middleware.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Write the request body back to the client
+	// Write the request body back to the client along with the negotiated protocol
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(ProtocolHeader, r.Proto)
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(body)
 }
 
-// HealthHandler is the health check handler
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
+// HealthHandler returns the legacy health check handler, backed by the readiness checks
+// It keeps the {"healthy":true} body but returns 503 with {"healthy":false} once a check fails or shutdown starts
+func HealthHandler(c *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, body := http.StatusOK, `{"healthy":true}`
+		if !c.Readiness(r.Context()).Healthy() {
+			status, body = http.StatusServiceUnavailable, `{"healthy":false}`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body + "\n"))
+	}
+}
+
+// LivezHandler returns a handler reporting whether the process is alive
+func LivezHandler(c *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness(r.Context()))
+	}
+}
+
+// ReadyzHandler returns a handler reporting whether the server should receive traffic
+// It fails once graceful shutdown starts so load balancers drain the instance
+func ReadyzHandler(c *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness(r.Context()))
+	}
+}
+
+// logLevelBody is the request and response body of the log level endpoints
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler returns a handler reporting the current log level
+func LogLevelHandler(level *slog.LevelVar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeLogLevel(w, level.Level())
+	}
+}
+
+// SetLogLevelHandler returns a handler that changes the log level from a body such as {"level":"debug"}
+// Levels use the slog names, so offsets such as "debug+2" are accepted as well
+func SetLogLevelHandler(level *slog.LevelVar, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body logLevelBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+
+		var next slog.Level
+		if err := next.UnmarshalText([]byte(body.Level)); err != nil {
+			middleware.WriteError(w, http.StatusBadRequest, fmt.Sprintf("unknown level %q, use debug, info, warn or error", body.Level))
+			return
+		}
+
+		previous := level.Level()
+		level.Set(next)
+		// Logged at warn or above so the change is not filtered out by the level it sets
+		logger.Log(r.Context(), max(slog.LevelWarn, next), "log level changed", "from", FormatLevel(previous), "to", FormatLevel(next))
+		writeLogLevel(w, next)
+	}
+}
+
+// FormatLevel returns the lower-case name of a log level, as used by LOG_LEVEL
+func FormatLevel(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// writeLogLevel writes the log level as JSON
+func writeLogLevel(w http.ResponseWriter, level slog.Level) {
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(`{"healthy":true}` + "\n"))
+	_ = json.NewEncoder(w).Encode(logLevelBody{Level: FormatLevel(level)})
+}
+
+// configResponse is the effective configuration, keyed by environment variable name
+type configResponse struct {
+	Settings  map[string]settingResponse `json:"settings"`
+	APIKeyIDs []string                   `json:"api_key_ids"`
+}
+
+// settingResponse is one setting and the layer that set it: default, file, env or flag
+type settingResponse struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// ConfigHandler returns a handler reporting the effective configuration with secrets redacted
+// API keys are listed by the IDs keyID derives from them, matching the identities in access logs
+func ConfigHandler(cfg *config.Config, keyID func(key string) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := configResponse{
+			Settings:  make(map[string]settingResponse),
+			APIKeyIDs: cfg.APIKeyIDs(keyID),
+		}
+		for _, s := range cfg.Settings() {
+			resp.Settings[s.Key] = settingResponse{Value: s.Value, Source: cfg.Source(s.Key)}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// writeReport writes a health report as JSON with 200 when healthy and 503 otherwise
+func writeReport(w http.ResponseWriter, report health.Report) {
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
 }
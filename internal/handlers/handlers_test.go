@@ -2,12 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/health"
+	"github.com/lkendrickd/echo-server/internal/middleware"
 )
 
 func TestEchoHandler(t *testing.T) {
@@ -73,6 +81,10 @@ func TestEchoHandler(t *testing.T) {
 			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
 				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
 			}
+
+			if proto := rec.Header().Get(ProtocolHeader); proto != "HTTP/1.1" {
+				t.Errorf("%s = %q, want %q", ProtocolHeader, proto, "HTTP/1.1")
+			}
 		})
 	}
 }
@@ -95,7 +107,7 @@ func TestEchoHandler_ReadError(t *testing.T) {
 	}
 
 	// Verify response is valid JSON with error field
-	var errResp errorResponse
+	var errResp middleware.ErrorResponse
 	if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
 		t.Errorf("failed to decode error response: %v", err)
 	}
@@ -109,10 +121,32 @@ func TestEchoHandler_ReadError(t *testing.T) {
 	}
 }
 
+func TestEchoHandler_BodyTooLarge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", strings.NewReader(strings.Repeat("a", 20)))
+	rec := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rec, req.Body, 10)
+
+	EchoHandler(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	var errResp middleware.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+
+	if want := "request body exceeds 10 bytes"; errResp.Error != want {
+		t.Errorf("error = %q, want %q", errResp.Error, want)
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	tests := []struct {
 		name        string
 		method      string
+		setup       func(c *health.Checker)
 		wantStatus  int
 		wantBody    string
 		contentType string
@@ -120,6 +154,7 @@ func TestHealthHandler(t *testing.T) {
 		{
 			name:        "GET request",
 			method:      http.MethodGet,
+			setup:       func(c *health.Checker) {},
 			wantStatus:  http.StatusOK,
 			wantBody:    `{"healthy":true}` + "\n",
 			contentType: "application/json",
@@ -127,10 +162,29 @@ func TestHealthHandler(t *testing.T) {
 		{
 			name:        "HEAD request",
 			method:      http.MethodHead,
+			setup:       func(c *health.Checker) {},
 			wantStatus:  http.StatusOK,
 			wantBody:    `{"healthy":true}` + "\n",
 			contentType: "application/json",
 		},
+		{
+			name:        "draining",
+			method:      http.MethodGet,
+			setup:       func(c *health.Checker) { c.SetDraining() },
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    `{"healthy":false}` + "\n",
+			contentType: "application/json",
+		},
+		{
+			name:   "failing readiness check",
+			method: http.MethodGet,
+			setup: func(c *health.Checker) {
+				c.AddReadiness("db", time.Second, func(ctx context.Context) error { return errors.New("down") })
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    `{"healthy":false}` + "\n",
+			contentType: "application/json",
+		},
 	}
 
 	for _, tt := range tests {
@@ -138,7 +192,9 @@ func TestHealthHandler(t *testing.T) {
 			req := httptest.NewRequest(tt.method, "/health", nil)
 			rec := httptest.NewRecorder()
 
-			HealthHandler(rec, req)
+			c := health.New()
+			tt.setup(c)
+			HealthHandler(c)(rec, req)
 
 			if rec.Code != tt.wantStatus {
 				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
@@ -154,3 +210,219 @@ func TestHealthHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthProbeHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(c *health.Checker)
+		handler    func(c *health.Checker) http.HandlerFunc
+		wantStatus int
+		wantReport string
+	}{
+		{
+			name:       "livez healthy",
+			setup:      func(c *health.Checker) {},
+			handler:    LivezHandler,
+			wantStatus: http.StatusOK,
+			wantReport: health.StatusOK,
+		},
+		{
+			name:       "readyz healthy",
+			setup:      func(c *health.Checker) {},
+			handler:    ReadyzHandler,
+			wantStatus: http.StatusOK,
+			wantReport: health.StatusOK,
+		},
+		{
+			name:       "readyz draining",
+			setup:      func(c *health.Checker) { c.SetDraining() },
+			handler:    ReadyzHandler,
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: health.StatusFail,
+		},
+		{
+			name: "readyz failing check",
+			setup: func(c *health.Checker) {
+				c.AddReadiness("upstream", 0, func(context.Context) error { return errors.New("down") })
+			},
+			handler:    ReadyzHandler,
+			wantStatus: http.StatusServiceUnavailable,
+			wantReport: health.StatusFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := health.New()
+			tt.setup(c)
+
+			rec := httptest.NewRecorder()
+			tt.handler(c)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+
+			var report health.Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+			if report.Status != tt.wantReport {
+				t.Errorf("report status = %q, want %q", report.Status, tt.wantReport)
+			}
+		})
+	}
+}
+
+func TestLogLevelHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantLevel  slog.Level
+	}{
+		{
+			name:       "set debug",
+			body:       `{"level":"debug"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"debug"}`,
+			wantLevel:  slog.LevelDebug,
+		},
+		{
+			name:       "set error",
+			body:       `{"level":"error"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"error"}`,
+			wantLevel:  slog.LevelError,
+		},
+		{
+			name:       "names are case insensitive",
+			body:       `{"level":"WARN"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"warn"}`,
+			wantLevel:  slog.LevelWarn,
+		},
+		{
+			name:       "offsets from a named level",
+			body:       `{"level":"info+2"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"level":"info+2"}`,
+			wantLevel:  slog.LevelInfo + 2,
+		},
+		{
+			name:       "unknown level",
+			body:       `{"level":"loud"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"unknown level \"loud\", use debug, info, warn or error"}`,
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "missing level",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  slog.LevelInfo,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `debug`,
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  slog.LevelInfo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: level}))
+
+			rec := httptest.NewRecorder()
+			SetLogLevelHandler(level, logger)(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.body)))
+
+			// The change is logged even when the new level filters out info
+			logged := strings.Contains(logs.String(), `"msg":"log level changed"`)
+			if wantLogged := tt.wantStatus == http.StatusOK; logged != wantLogged {
+				t.Errorf("change logged = %v, want %v, logs: %s", logged, wantLogged, logs.String())
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if level.Level() != tt.wantLevel {
+				t.Errorf("level = %v, want %v", level.Level(), tt.wantLevel)
+			}
+
+			rec = httptest.NewRecorder()
+			LogLevelHandler(level)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			want := `{"level":"` + FormatLevel(tt.wantLevel) + `"}`
+			if got := strings.TrimSpace(rec.Body.String()); got != want {
+				t.Errorf("GET body = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	t.Setenv("API_KEYS", "secret-key-1,secret-key-2")
+	t.Setenv("METRICS_TOKEN", "secret-token")
+	cfg := config.New()
+	cfg.Port = "9000"
+	cfg.SetSource("PORT", config.SourceFlag)
+
+	// The ID is the key's last character, so IDs can be checked without exposing the keys
+	keyID := func(key string) string { return "id-" + key[len(key)-1:] }
+
+	rec := httptest.NewRecorder()
+	ConfigHandler(cfg, keyID)(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/config", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	for _, secret := range []string{"secret-key-1", "secret-token"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("body leaks %q", secret)
+		}
+	}
+
+	var resp struct {
+		Settings map[string]struct {
+			Value  string `json:"value"`
+			Source string `json:"source"`
+		} `json:"settings"`
+		APIKeyIDs []string `json:"api_key_ids"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+
+	tests := []struct {
+		key        string
+		wantValue  string
+		wantSource string
+	}{
+		{key: "PORT", wantValue: "9000", wantSource: config.SourceFlag},
+		{key: "API_KEYS", wantValue: config.Redacted + " (2 keys)", wantSource: config.SourceEnv},
+		{key: "METRICS_TOKEN", wantValue: config.Redacted, wantSource: config.SourceEnv},
+		{key: "READ_TIMEOUT", wantValue: "15s", wantSource: config.SourceDefault},
+	}
+	for _, tt := range tests {
+		got := resp.Settings[tt.key]
+		if got.Value != tt.wantValue || got.Source != tt.wantSource {
+			t.Errorf("%s = %q from %q, want %q from %q", tt.key, got.Value, got.Source, tt.wantValue, tt.wantSource)
+		}
+	}
+
+	wantIDs := []string{"id-1", "id-2"}
+	if strings.Join(resp.APIKeyIDs, ",") != strings.Join(wantIDs, ",") {
+		t.Errorf("api_key_ids = %v, want %v", resp.APIKeyIDs, wantIDs)
+	}
+}
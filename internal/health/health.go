@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds checks registered without an explicit timeout
+const DefaultTimeout = 5 * time.Second
+
+// Check statuses reported in a Report
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown is reported by the readiness shutdown check once draining has started
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// CheckFunc reports the health of a dependency, returning nil when healthy
+type CheckFunc func(ctx context.Context) error
+
+// check is a named CheckFunc with its timeout
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the aggregated outcome of a set of checks
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Healthy reports whether every check passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Checker holds the liveness and readiness checks for a server
+type Checker struct {
+	mu        sync.RWMutex
+	liveness  []check
+	readiness []check
+	draining  atomic.Bool
+}
+
+// New creates a Checker whose readiness includes a built-in shutdown check
+func New() *Checker {
+	c := &Checker{}
+	c.AddReadiness("shutdown", 0, func(context.Context) error {
+		if c.draining.Load() {
+			return ErrShuttingDown
+		}
+		return nil
+	})
+	return c
+}
+
+// AddLiveness registers a check that must pass for the process to be considered alive
+// A timeout of zero uses DefaultTimeout
+func (c *Checker) AddLiveness(name string, timeout time.Duration, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness = append(c.liveness, check{name: name, timeout: timeout, fn: fn})
+}
+
+// AddReadiness registers a check that must pass for the server to receive traffic
+// A timeout of zero uses DefaultTimeout
+func (c *Checker) AddReadiness(name string, timeout time.Duration, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness = append(c.readiness, check{name: name, timeout: timeout, fn: fn})
+}
+
+// SetDraining marks the server as shutting down so readiness fails and load balancers drain it
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Draining reports whether SetDraining has been called
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Liveness runs the liveness checks
+func (c *Checker) Liveness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.liveness
+	c.mu.RUnlock()
+
+	return run(ctx, checks)
+}
+
+// Readiness runs the readiness checks
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.readiness
+	c.mu.RUnlock()
+
+	return run(ctx, checks)
+}
+
+// run executes the checks concurrently, each bounded by its own timeout
+func run(ctx context.Context, checks []check) Report {
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Go(func() {
+			results[i] = runCheck(ctx, chk)
+		})
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+	return report
+}
+
+// runCheck executes a single check, treating a timeout as a failure
+func runCheck(ctx context.Context, chk check) CheckResult {
+	timeout := chk.timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- chk.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{
+		Name:     chk.name,
+		Status:   StatusOK,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
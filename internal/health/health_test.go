@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker_Readiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(c *Checker)
+		wantStatus string
+		wantFailed []string
+	}{
+		{
+			name:       "only built-in shutdown check",
+			setup:      func(c *Checker) {},
+			wantStatus: StatusOK,
+		},
+		{
+			name: "passing custom check",
+			setup: func(c *Checker) {
+				c.AddReadiness("database", time.Second, func(context.Context) error { return nil })
+			},
+			wantStatus: StatusOK,
+		},
+		{
+			name: "failing custom check",
+			setup: func(c *Checker) {
+				c.AddReadiness("database", time.Second, func(context.Context) error { return errors.New("connection refused") })
+			},
+			wantStatus: StatusFail,
+			wantFailed: []string{"database"},
+		},
+		{
+			name: "check exceeding its timeout",
+			setup: func(c *Checker) {
+				c.AddReadiness("slow", 10*time.Millisecond, func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				})
+			},
+			wantStatus: StatusFail,
+			wantFailed: []string{"slow"},
+		},
+		{
+			name: "check ignoring its context still times out",
+			setup: func(c *Checker) {
+				c.AddReadiness("stuck", 10*time.Millisecond, func(context.Context) error {
+					time.Sleep(200 * time.Millisecond)
+					return nil
+				})
+			},
+			wantStatus: StatusFail,
+			wantFailed: []string{"stuck"},
+		},
+		{
+			name:       "draining fails readiness",
+			setup:      func(c *Checker) { c.SetDraining() },
+			wantStatus: StatusFail,
+			wantFailed: []string{"shutdown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			tt.setup(c)
+
+			report := c.Readiness(context.Background())
+
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", report.Status, tt.wantStatus)
+			}
+
+			var failed []string
+			for _, res := range report.Checks {
+				if res.Status == StatusFail {
+					failed = append(failed, res.Name)
+					if res.Error == "" {
+						t.Errorf("check %q failed without an error message", res.Name)
+					}
+				}
+			}
+			if len(failed) != len(tt.wantFailed) {
+				t.Fatalf("failed checks = %v, want %v", failed, tt.wantFailed)
+			}
+			for i := range failed {
+				if failed[i] != tt.wantFailed[i] {
+					t.Errorf("failed checks = %v, want %v", failed, tt.wantFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_Liveness(t *testing.T) {
+	c := New()
+
+	// Draining only affects readiness
+	c.SetDraining()
+	if report := c.Liveness(context.Background()); !report.Healthy() {
+		t.Errorf("liveness = %+v, want healthy while draining", report)
+	}
+
+	c.AddLiveness("deadlock", 0, func(context.Context) error { return errors.New("worker stalled") })
+	if report := c.Liveness(context.Background()); report.Healthy() {
+		t.Error("liveness healthy with failing check")
+	}
+
+	if !c.Draining() {
+		t.Error("Draining() = false after SetDraining")
+	}
+}
@@ -0,0 +1,184 @@
+// Package listener opens TCP, Unix domain socket and systemd socket-activated listeners from address strings
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Address prefixes selecting the listener type, anything else is a TCP address such as ":8080"
+const (
+	UnixPrefix    = "unix:"
+	SystemdPrefix = "systemd"
+)
+
+// DefaultUnixSocketMode is the file mode applied to Unix sockets when none is configured
+const DefaultUnixSocketMode fs.FileMode = 0o660
+
+// Options configures how listeners are opened
+type Options struct {
+	// UnixSocketMode is the permission applied to Unix socket files, 0 uses DefaultUnixSocketMode
+	UnixSocketMode fs.FileMode
+}
+
+// Listen opens a listener for addr
+//
+//	":8080", "127.0.0.1:0"  TCP
+//	"unix:/run/echo.sock"   Unix domain socket, removed again when the listener is closed
+//	"systemd"               the first socket passed by systemd socket activation
+//	"systemd:web"           the activated socket named "web" via FileDescriptorName=
+func Listen(addr string, opts Options) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, UnixPrefix):
+		return listenUnix(strings.TrimPrefix(addr, UnixPrefix), opts)
+	case addr == SystemdPrefix:
+		return activated("")
+	case strings.HasPrefix(addr, SystemdPrefix+":"):
+		return activated(strings.TrimPrefix(addr, SystemdPrefix+":"))
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+// listenUnix binds a Unix socket at path, replacing a stale socket file left by a previous run
+func listenUnix(path string, opts Options) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Sockets created by Listen are unlinked on Close, which cleans up on shutdown
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	mode := opts.UnixSocketMode
+	if mode == 0 {
+		mode = DefaultUnixSocketMode
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("chmod unix socket %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket deletes a socket file nobody is accepting on, live sockets and other files are left alone
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+// listenFDsStart is the first file descriptor passed by systemd, SD_LISTEN_FDS_START
+var listenFDsStart = 3
+
+// activation holds the sockets passed by systemd, loaded once per process
+var activation struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []namedListener
+	err       error
+}
+
+// namedListener is an activated socket with its FileDescriptorName
+type namedListener struct {
+	name    string
+	ln      net.Listener
+	claimed bool
+}
+
+// activated claims the activated socket with the given name, or the first unclaimed one if name is empty
+// Each socket can be claimed once so several listeners never share a descriptor
+func activated(name string) (net.Listener, error) {
+	activation.once.Do(func() {
+		activation.listeners, activation.err = loadActivated()
+	})
+
+	activation.mu.Lock()
+	defer activation.mu.Unlock()
+
+	if activation.err != nil {
+		return nil, activation.err
+	}
+	if len(activation.listeners) == 0 {
+		return nil, errors.New("no sockets passed by systemd socket activation")
+	}
+	for i := range activation.listeners {
+		nl := &activation.listeners[i]
+		if nl.claimed || (name != "" && nl.name != name) {
+			continue
+		}
+		nl.claimed = true
+		return nl.ln, nil
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no unclaimed systemd socket named %q", name)
+	}
+	return nil, errors.New("all systemd sockets are already in use")
+}
+
+// loadActivated converts the descriptors described by LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES into listeners
+// The variables are unset afterwards so child processes do not inherit them
+func loadActivated() ([]namedListener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]namedListener, 0, count)
+	for i := range count {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		ln, err := net.FileListener(f)
+		// FileListener duplicates the descriptor, so the original is closed either way
+		_ = f.Close()
+		if err != nil {
+			for _, nl := range listeners {
+				_ = nl.ln.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %s: %w", name, err)
+		}
+		listeners = append(listeners, namedListener{name: name, ln: ln})
+	}
+	return listeners, nil
+}
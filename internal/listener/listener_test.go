@@ -0,0 +1,107 @@
+package listener
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListen_TCP(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0", Options{})
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	if _, ok := ln.Addr().(*net.TCPAddr); !ok {
+		t.Errorf("Addr() = %T, want *net.TCPAddr", ln.Addr())
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     fs.FileMode
+		wantMode fs.FileMode
+	}{
+		{name: "default mode", wantMode: DefaultUnixSocketMode},
+		{name: "custom mode", mode: 0o600, wantMode: 0o600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "echo.sock")
+
+			ln, err := Listen(UnixPrefix+path, Options{UnixSocketMode: tt.mode})
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("socket file missing: %v", err)
+			}
+			if got := info.Mode().Perm(); got != tt.wantMode {
+				t.Errorf("socket mode = %o, want %o", got, tt.wantMode)
+			}
+
+			if err := ln.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("socket file not removed on close, stat error = %v", err)
+			}
+		})
+	}
+}
+
+func TestListen_UnixExistingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("stale socket is replaced", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		old, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatalf("net.Listen: %v", err)
+		}
+		// Leave the file behind as a crashed process would
+		old.(*net.UnixListener).SetUnlinkOnClose(false)
+		_ = old.Close()
+
+		ln, err := Listen(UnixPrefix+path, Options{})
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		_ = ln.Close()
+	})
+
+	t.Run("live socket is refused", func(t *testing.T) {
+		path := filepath.Join(dir, "live.sock")
+		live, err := Listen(UnixPrefix+path, Options{})
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		defer live.Close()
+
+		if _, err := Listen(UnixPrefix+path, Options{}); err == nil {
+			t.Error("Listen() on a live socket succeeded, want error")
+		}
+	})
+
+	t.Run("regular file is refused", func(t *testing.T) {
+		path := filepath.Join(dir, "file.sock")
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Listen(UnixPrefix+path, Options{}); err == nil {
+			t.Error("Listen() over a regular file succeeded, want error")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("regular file was removed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProxyHeaderTimeout bounds how long a connection may take to send its PROXY header
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// errUntrustedProxy rejects connections from peers outside the trusted ranges
+var errUntrustedProxy = errors.New("proxy protocol: connection from untrusted peer")
+
+// ProxyOptions configures PROXY protocol handling
+type ProxyOptions struct {
+	// Trusted limits which peers may send PROXY headers, connections from other peers are closed
+	// An empty list trusts only Unix socket peers, so no TCP client can claim another address
+	Trusted []netip.Prefix
+	// HeaderTimeout bounds reading the header, 0 uses DefaultProxyHeaderTimeout
+	HeaderTimeout time.Duration
+}
+
+// NewProxyListener wraps ln so every accepted connection must start with a PROXY protocol v1 or v2 header
+// The header is read lazily on the connection's own goroutine so slow clients never block Accept
+// RemoteAddr and LocalAddr report the addresses carried in the header
+func NewProxyListener(ln net.Listener, opts ProxyOptions) net.Listener {
+	if opts.HeaderTimeout <= 0 {
+		opts.HeaderTimeout = DefaultProxyHeaderTimeout
+	}
+	return &proxyListener{Listener: ln, opts: opts}
+}
+
+// proxyListener wraps accepted connections in proxyConn
+type proxyListener struct {
+	net.Listener
+	opts ProxyOptions
+}
+
+// Accept waits for the next connection and wraps it
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, opts: l.opts, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn parses the PROXY header before the first read or address lookup
+type proxyConn struct {
+	net.Conn
+	opts   ProxyOptions
+	reader *bufio.Reader
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+// Read returns data following the PROXY header, or the header error
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header, or the peer if there is none
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the PROXY header, or the local socket address
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader checks the peer is trusted and parses a v1 or v2 header under the header timeout
+func (c *proxyConn) readHeader() {
+	if !c.trusted() {
+		c.err = errUntrustedProxy
+		return
+	}
+
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.opts.HeaderTimeout))
+	defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+
+	sig, err := c.reader.Peek(len(proxyV2Signature))
+	switch {
+	case err == nil && bytes.Equal(sig, proxyV2Signature):
+		c.err = c.readV2()
+	case len(sig) >= 6 && string(sig[:6]) == "PROXY ":
+		c.err = c.readV1()
+	case err != nil:
+		c.err = fmt.Errorf("proxy protocol: reading header: %w", err)
+	default:
+		c.err = errors.New("proxy protocol: missing header")
+	}
+}
+
+// trusted reports whether the peer may send PROXY headers
+func (c *proxyConn) trusted() bool {
+	ip, ok := addrIP(c.Conn.RemoteAddr())
+	if !ok {
+		// Unix socket peers are local processes
+		_, unix := c.Conn.RemoteAddr().(*net.UnixAddr)
+		return unix
+	}
+	for _, p := range c.opts.Trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// readV1 parses a text header such as "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func (c *proxyConn) readV1() error {
+	// The longest valid v1 header is 107 bytes including CRLF
+	var line []byte
+	for len(line) < 107 {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("proxy protocol: reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("proxy protocol: v1 header too long")
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("proxy protocol: malformed v1 header %q", line)
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+	c.remoteAddr, c.localAddr = src, dst
+	return nil
+}
+
+// parseV1Addr builds a TCP address from the textual ip and port of a v1 header
+func parseV1Addr(ip, port string) (*net.TCPAddr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid address %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid port %q", port)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// readV2 parses a binary header, LOCAL commands and non-TCP families keep the socket addresses
+func (c *proxyConn) readV2() error {
+	var hdr [16]byte
+	if _, err := io.ReadFull(c.reader, hdr[:]); err != nil {
+		return fmt.Errorf("proxy protocol: reading v2 header: %w", err)
+	}
+	if hdr[12]>>4 != 2 {
+		return fmt.Errorf("proxy protocol: unsupported version %d", hdr[12]>>4)
+	}
+	command := hdr[12] & 0x0f
+	family := hdr[13]
+
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return fmt.Errorf("proxy protocol: reading v2 addresses: %w", err)
+	}
+
+	switch command {
+	case 0x0:
+		// LOCAL, sent by the proxy for its own health checks
+		return nil
+	case 0x1:
+	default:
+		return fmt.Errorf("proxy protocol: unsupported v2 command %d", command)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return errors.New("proxy protocol: short v2 IPv4 addresses")
+		}
+		c.remoteAddr = v2Addr(payload[0:4], payload[8:10])
+		c.localAddr = v2Addr(payload[4:8], payload[10:12])
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return errors.New("proxy protocol: short v2 IPv6 addresses")
+		}
+		c.remoteAddr = v2Addr(payload[0:16], payload[32:34])
+		c.localAddr = v2Addr(payload[16:32], payload[34:36])
+	}
+	return nil
+}
+
+// v2Addr builds a TCP address from the raw ip and big-endian port of a v2 header
+func v2Addr(ip, port []byte) *net.TCPAddr {
+	addr, _ := netip.AddrFromSlice(ip)
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr.Unmap(), binary.BigEndian.Uint16(port)))
+}
+
+// addrIP extracts the IP of a TCP or UDP address
+func addrIP(addr net.Addr) (netip.Addr, bool) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	case *net.UDPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	default:
+		return netip.Addr{}, false
+	}
+}
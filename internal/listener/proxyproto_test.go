@@ -0,0 +1,191 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// v2Header builds a binary PROXY header for the given command, family and address payload
+func v2Header(command, family byte, payload []byte) []byte {
+	hdr := append([]byte{}, proxyV2Signature...)
+	hdr = append(hdr, 0x20|command, family)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(payload)))
+	return append(hdr, payload...)
+}
+
+// v2Payload builds the address block for a TCP header
+func v2Payload(src, dst string, srcPort, dstPort uint16) []byte {
+	var b []byte
+	b = append(b, netip.MustParseAddr(src).AsSlice()...)
+	b = append(b, netip.MustParseAddr(dst).AsSlice()...)
+	b = binary.BigEndian.AppendUint16(b, srcPort)
+	return binary.BigEndian.AppendUint16(b, dstPort)
+}
+
+func TestProxyListener(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     []byte
+		trusted    []netip.Prefix
+		untrusted  bool
+		wantRemote string
+		wantLocal  string
+		wantErr    bool
+	}{
+		{
+			name:       "v1 tcp4",
+			header:     []byte("PROXY TCP4 192.0.2.10 198.51.100.1 56324 443\r\n"),
+			wantRemote: "192.0.2.10:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:       "v1 tcp6",
+			header:     []byte("PROXY TCP6 2001:db8::10 2001:db8::1 56324 443\r\n"),
+			wantRemote: "[2001:db8::10]:56324",
+			wantLocal:  "[2001:db8::1]:443",
+		},
+		{
+			name:   "v1 unknown keeps socket addresses",
+			header: []byte("PROXY UNKNOWN\r\n"),
+		},
+		{
+			name:       "v2 tcp4",
+			header:     v2Header(0x1, 0x11, v2Payload("192.0.2.10", "198.51.100.1", 56324, 443)),
+			wantRemote: "192.0.2.10:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:       "v2 tcp6",
+			header:     v2Header(0x1, 0x21, v2Payload("2001:db8::10", "2001:db8::1", 56324, 443)),
+			wantRemote: "[2001:db8::10]:56324",
+			wantLocal:  "[2001:db8::1]:443",
+		},
+		{
+			name:   "v2 local keeps socket addresses",
+			header: v2Header(0x0, 0x00, nil),
+		},
+		{
+			name:       "trusted peer",
+			header:     []byte("PROXY TCP4 192.0.2.10 198.51.100.1 56324 443\r\n"),
+			trusted:    []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			wantRemote: "192.0.2.10:56324",
+			wantLocal:  "198.51.100.1:443",
+		},
+		{
+			name:    "untrusted peer",
+			header:  []byte("PROXY TCP4 192.0.2.10 198.51.100.1 56324 443\r\n"),
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			wantErr: true,
+		},
+		{
+			name:      "no trusted proxies",
+			header:    []byte("PROXY TCP4 192.0.2.10 198.51.100.1 56324 443\r\n"),
+			untrusted: true,
+			wantErr:   true,
+		},
+		{
+			name:    "missing header",
+			header:  []byte("GET / HTTP/1.1\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "malformed v1 header",
+			header:  []byte("PROXY TCP4 not-an-ip 198.51.100.1 56324 443\r\n"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("net.Listen: %v", err)
+			}
+			// The test client connects over loopback, which is trusted unless the case sets its own ranges
+			trusted := tt.trusted
+			if trusted == nil && !tt.untrusted {
+				trusted = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+			}
+			ln := NewProxyListener(inner, ProxyOptions{Trusted: trusted, HeaderTimeout: time.Second})
+			defer ln.Close()
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("net.Dial: %v", err)
+			}
+			defer client.Close()
+			payload := []byte("hello")
+			_, _ = client.Write(append(tt.header, payload...))
+
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Fatalf("Accept() error = %v", err)
+			}
+			defer conn.Close()
+
+			got := make([]byte, len(payload))
+			_, err = io.ReadFull(conn, got)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Read() error = nil, want header error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("payload = %q, want %q", got, payload)
+			}
+
+			wantRemote, wantLocal := tt.wantRemote, tt.wantLocal
+			if wantRemote == "" {
+				wantRemote, wantLocal = client.LocalAddr().String(), client.RemoteAddr().String()
+			}
+			if got := conn.RemoteAddr().String(); got != wantRemote {
+				t.Errorf("RemoteAddr() = %q, want %q", got, wantRemote)
+			}
+			if got := conn.LocalAddr().String(); got != wantLocal {
+				t.Errorf("LocalAddr() = %q, want %q", got, wantLocal)
+			}
+		})
+	}
+}
+
+func TestProxyListener_HeaderTimeout(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	ln := NewProxyListener(inner, ProxyOptions{
+		Trusted:       []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+		HeaderTimeout: 50 * time.Millisecond,
+	})
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial: %v", err)
+	}
+	defer client.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+
+	// A silent client must not hold the connection open past the header timeout
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("header read took %v, want about 50ms", elapsed)
+	}
+}
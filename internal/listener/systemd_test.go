@@ -0,0 +1,111 @@
+//go:build unix
+
+package listener
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// resetActivation clears the cached systemd sockets between tests
+func resetActivation(t *testing.T) {
+	t.Helper()
+
+	activation.once = sync.Once{}
+	activation.listeners = nil
+	activation.err = nil
+	t.Cleanup(func() {
+		for _, nl := range activation.listeners {
+			_ = nl.ln.Close()
+		}
+		activation.once = sync.Once{}
+		activation.listeners = nil
+		activation.err = nil
+		listenFDsStart = 3
+	})
+}
+
+// passSockets simulates systemd passing the given listeners as consecutive descriptors
+func passSockets(t *testing.T, names string, count int) {
+	t.Helper()
+
+	// Raw descriptors are used so no *os.File finalizer closes them after activation takes ownership
+	var fds []int
+	for len(fds) < count {
+		fd := socketFD(t)
+		if len(fds) > 0 && fd != fds[len(fds)-1]+1 {
+			for _, old := range fds {
+				_ = syscall.Close(old)
+			}
+			fds = nil
+		}
+		fds = append(fds, fd)
+	}
+	listenFDsStart = fds[0]
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(count))
+	t.Setenv("LISTEN_FDNAMES", names)
+}
+
+// socketFD returns a raw duplicate of a listening TCP socket
+func socketFD(t *testing.T) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer ln.Close()
+
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File(): %v", err)
+	}
+	defer f.Close()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("syscall.Dup: %v", err)
+	}
+	return fd
+}
+
+func TestListen_Systemd(t *testing.T) {
+	resetActivation(t)
+	passSockets(t, "web:admin", 2)
+
+	admin, err := Listen(SystemdPrefix+":admin", Options{})
+	if err != nil {
+		t.Fatalf("Listen(systemd:admin) error = %v", err)
+	}
+	first, err := Listen(SystemdPrefix, Options{})
+	if err != nil {
+		t.Fatalf("Listen(systemd) error = %v", err)
+	}
+	if admin.Addr().String() == first.Addr().String() {
+		t.Error("named and unnamed listeners share a socket")
+	}
+
+	if _, err := Listen(SystemdPrefix, Options{}); err == nil {
+		t.Error("Listen(systemd) with every socket claimed succeeded, want error")
+	}
+
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS still set after activation")
+	}
+}
+
+func TestListen_SystemdNotActivated(t *testing.T) {
+	resetActivation(t)
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	if _, err := Listen(SystemdPrefix, Options{}); err == nil {
+		t.Error("Listen(systemd) for another process succeeded, want error")
+	}
+}
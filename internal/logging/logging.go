@@ -0,0 +1,116 @@
+// Package logging builds the application's slog handler from the configured format and destination
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log formats
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+	FormatText   = "text"
+)
+
+// Outputs other than a file path
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// Options configures the handler returned by New
+type Options struct {
+	// Format is json, logfmt or text, defaults to json
+	Format string
+	// Output is stdout, stderr or a file path, defaults to stdout
+	Output string
+	// MaxSize rotates an output file once it reaches this many bytes, 0 never rotates
+	MaxSize int64
+	// MaxBackups is how many rotated files are kept
+	MaxBackups int
+
+	// Level is the minimum level logged, defaults to info
+	Level slog.Leveler
+	// AddSource adds the source file and line to every record
+	AddSource bool
+	// Attrs are added to every record
+	Attrs []slog.Attr
+
+	// Stdout and Stderr replace os.Stdout and os.Stderr when set
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// New creates a handler writing opts.Format records to opts.Output
+// The returned closer closes the output file, it does nothing for stdout and stderr
+func New(opts Options) (slog.Handler, io.Closer, error) {
+	w, closer, err := openOutput(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level, AddSource: opts.AddSource}
+	var h slog.Handler
+	switch opts.Format {
+	case FormatJSON, "":
+		h = slog.NewJSONHandler(w, handlerOpts)
+	case FormatLogfmt:
+		h = slog.NewTextHandler(w, handlerOpts)
+	case FormatText:
+		h = NewTextHandler(w, handlerOpts, colorEnabled(w))
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q, use json, logfmt or text", opts.Format)
+	}
+
+	if len(opts.Attrs) > 0 {
+		h = h.WithAttrs(opts.Attrs)
+	}
+	return h, closer, nil
+}
+
+// openOutput returns the writer for opts.Output and its closer
+func openOutput(opts Options) (io.Writer, io.Closer, error) {
+	switch opts.Output {
+	case OutputStdout, "":
+		return orDefault(opts.Stdout, os.Stdout), nopCloser{}, nil
+	case OutputStderr:
+		return orDefault(opts.Stderr, os.Stderr), nopCloser{}, nil
+	default:
+		f, err := OpenRotatingFile(opts.Output, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f, nil
+	}
+}
+
+// orDefault returns w, or def when w is nil
+func orDefault(w, def io.Writer) io.Writer {
+	if w == nil {
+		return def
+	}
+	return w
+}
+
+// colorEnabled reports whether w is a terminal and NO_COLOR is unset
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// nopCloser is the closer for outputs the logger does not own
+type nopCloser struct{}
+
+// Close does nothing
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "json by default",
+			format: "",
+			want:   []string{`"level":"INFO"`, `"msg":"listening"`, `"addr":":8080"`, `"service":"echo"`},
+		},
+		{
+			name:   "logfmt",
+			format: FormatLogfmt,
+			want:   []string{"level=INFO", "msg=listening", "addr=:8080", "service=echo"},
+		},
+		{
+			name:   "text",
+			format: FormatText,
+			want:   []string{"INFO  listening", "addr=:8080", "service=echo"},
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			h, closer, err := New(Options{
+				Format: tt.format,
+				Attrs:  []slog.Attr{slog.String("service", "echo")},
+				Stdout: &stdout,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("New() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer closer.Close()
+
+			slog.New(h).Info("listening", "addr", ":8080")
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("output = %q, want it to contain %q", stdout.String(), want)
+				}
+			}
+			if strings.Contains(stdout.String(), "\x1b[") {
+				t.Errorf("output = %q, want no color codes outside a terminal", stdout.String())
+			}
+		})
+	}
+}
+
+func TestNew_Outputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "echo.log")
+
+	tests := []struct {
+		name       string
+		output     string
+		wantStdout bool
+		wantStderr bool
+		wantFile   bool
+	}{
+		{name: "stdout by default", output: "", wantStdout: true},
+		{name: "stderr", output: OutputStderr, wantStderr: true},
+		{name: "file", output: path, wantFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			h, closer, err := New(Options{Output: tt.output, Stdout: &stdout, Stderr: &stderr})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			slog.New(h).Info("hello")
+			if err := closer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := stdout.Len() > 0; got != tt.wantStdout {
+				t.Errorf("wrote to stdout = %v, want %v", got, tt.wantStdout)
+			}
+			if got := stderr.Len() > 0; got != tt.wantStderr {
+				t.Errorf("wrote to stderr = %v, want %v", got, tt.wantStderr)
+			}
+			if tt.wantFile {
+				data, err := os.ReadFile(path)
+				if err != nil || !strings.Contains(string(data), `"msg":"hello"`) {
+					t.Errorf("log file = %q, %v, want the record", data, err)
+				}
+			}
+		})
+	}
+
+	if _, _, err := New(Options{Output: filepath.Join(t.TempDir(), "missing", "echo.log")}); err == nil {
+		t.Error("New() with an unwritable file error = nil, want an error")
+	}
+}
+
+func TestNew_Source(t *testing.T) {
+	var stdout bytes.Buffer
+	h, _, err := New(Options{AddSource: true, Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	slog.New(h).Info("hello")
+
+	var record struct {
+		Source struct {
+			File string `json:"file"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !strings.HasSuffix(record.Source.File, "logging_test.go") {
+		t.Errorf("source file = %q, want logging_test.go", record.Source.File)
+	}
+}
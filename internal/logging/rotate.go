@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// RotatingFile is an append-only log file that is rotated once it reaches a size limit
+// The current file is renamed to path.1, older files shift to path.2 and so on, and the oldest is dropped
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending, maxSize 0 never rotates
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p, rotating first if p would take the file past its size limit
+// A record is never split across files, so a single record larger than the limit gets a file of its own
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil && f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	// A failed rotation leaves the file closed, retry opening it on every write
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the current file and records its size
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate closes the current file, shifts the backups and opens a new file
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+
+	if f.maxBackups == 0 {
+		err = errors.Join(err, ignoreNotExist(os.Remove(f.path)))
+	} else {
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			err = errors.Join(err, ignoreNotExist(os.Rename(f.backup(i), f.backup(i+1))))
+		}
+		err = errors.Join(err, ignoreNotExist(os.Rename(f.path, f.backup(1))))
+	}
+	if err != nil {
+		return fmt.Errorf("rotate %s: %w", f.path, err)
+	}
+	return f.open()
+}
+
+// backup returns the path of the nth rotated file
+func (f *RotatingFile) backup(n int) string {
+	return f.path + "." + strconv.Itoa(n)
+}
+
+// ignoreNotExist drops errors for files that do not exist yet
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
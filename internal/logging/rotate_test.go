@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     []string
+		want       map[string]string
+	}{
+		{
+			name:       "no rotation below the limit",
+			maxSize:    100,
+			maxBackups: 2,
+			writes:     []string{"one\n", "two\n"},
+			want:       map[string]string{"app.log": "one\ntwo\n"},
+		},
+		{
+			name:       "rotates before exceeding the limit",
+			maxSize:    8,
+			maxBackups: 2,
+			writes:     []string{"one\n", "two\n", "three\n"},
+			want:       map[string]string{"app.log": "three\n", "app.log.1": "one\ntwo\n"},
+		},
+		{
+			name:       "drops the oldest backup",
+			maxSize:    4,
+			maxBackups: 2,
+			writes:     []string{"one\n", "two\n", "six\n", "ten\n"},
+			want:       map[string]string{"app.log": "ten\n", "app.log.1": "six\n", "app.log.2": "two\n"},
+		},
+		{
+			name:       "no backups truncates",
+			maxSize:    4,
+			maxBackups: 0,
+			writes:     []string{"one\n", "two\n"},
+			want:       map[string]string{"app.log": "two\n"},
+		},
+		{
+			name:       "zero size never rotates",
+			maxSize:    0,
+			maxBackups: 2,
+			writes:     []string{"one\n", "two\n"},
+			want:       map[string]string{"app.log": "one\ntwo\n"},
+		},
+		{
+			name:       "oversized record gets its own file",
+			maxSize:    4,
+			maxBackups: 1,
+			writes:     []string{"one\n", "eleven\n"},
+			want:       map[string]string{"app.log": "eleven\n", "app.log.1": "one\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := OpenRotatingFile(filepath.Join(dir, "app.log"), tt.maxSize, tt.maxBackups)
+			if err != nil {
+				t.Fatalf("OpenRotatingFile() error = %v", err)
+			}
+			for _, w := range tt.writes {
+				if _, err := f.Write([]byte(w)); err != nil {
+					t.Fatalf("Write(%q) error = %v", w, err)
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.want) {
+				t.Errorf("files = %d, want %d", len(entries), len(tt.want))
+			}
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("ReadFile(%s) error = %v", name, err)
+					continue
+				}
+				if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	f, err := OpenRotatingFile(path, 8, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer f.Close()
+
+	// The existing size counts towards the limit
+	_, _ = f.Write([]byte("new\n"))
+	_, _ = f.Write([]byte("more\n"))
+
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "old\nnew\n" {
+		t.Errorf("backup = %q, want the old and first new record", data)
+	}
+}
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ANSI escape sequences used by the text format
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// textHandler writes human-readable records such as "15:04:05.000 INFO  listening addr=:8080"
+type textHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	opts  slog.HandlerOptions
+	color bool
+
+	// attrs holds the formatted attributes added by WithAttrs
+	attrs string
+	// prefix qualifies attribute keys with the groups opened by WithGroup
+	prefix string
+}
+
+// NewTextHandler creates a handler for reading logs in a terminal, with ANSI colors when color is true
+func NewTextHandler(w io.Writer, opts *slog.HandlerOptions, color bool) slog.Handler {
+	h := &textHandler{w: w, mu: new(sync.Mutex), color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether records at level are logged
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle formats r on a single line
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	if !r.Time.IsZero() {
+		buf = h.paint(buf, ansiDim, r.Time.Format(time.DateTime+".000"))
+		buf = append(buf, ' ')
+	}
+	buf = h.paint(buf, levelColor(r.Level), fmt.Sprintf("%-5s", r.Level.String()))
+	buf = append(buf, ' ')
+	buf = append(buf, r.Message...)
+
+	if h.opts.AddSource && r.PC != 0 {
+		source := r.Source()
+		buf = append(buf, ' ')
+		buf = h.paint(buf, ansiDim, filepath.Base(source.File)+":"+strconv.Itoa(source.Line))
+	}
+
+	buf = append(buf, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, h.prefix, a)
+		return true
+	})
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	buf := []byte(h.attrs)
+	for _, a := range attrs {
+		buf = h.appendAttr(buf, h.prefix, a)
+	}
+	h2.attrs = string(buf)
+	return &h2
+}
+
+// WithGroup returns a handler that qualifies later attribute keys with name
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr appends " key=value", flattening groups into dotted keys
+func (h *textHandler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			buf = h.appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	buf = h.paint(buf, ansiCyan, prefix+a.Key+"=")
+	return append(buf, quoteIfNeeded(formatValue(a.Value))...)
+}
+
+// paint appends s, wrapped in the color code when colors are enabled
+func (h *textHandler) paint(buf []byte, color, s string) []byte {
+	if !h.color {
+		return append(buf, s...)
+	}
+	buf = append(buf, color...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+// levelColor returns the color for a level, levels between names use the color of the one below
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	default:
+		return ansiBlue
+	}
+}
+
+// formatValue formats v, using RFC 3339 for times so they stay on one token
+func formatValue(v slog.Value) string {
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
+
+// quoteIfNeeded quotes s when it is empty or contains spaces, quotes, '=' or control characters
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		log   func(l *slog.Logger)
+		color bool
+		want  string
+	}{
+		{
+			name: "message and attributes",
+			log:  func(l *slog.Logger) { l.Info("listening", "addr", ":8080", "tls", false) },
+			want: "INFO  listening addr=:8080 tls=false",
+		},
+		{
+			name: "values that need quoting",
+			log:  func(l *slog.Logger) { l.Warn("slow", "path", "/a b", "empty", "", "err", errors.New(`say "hi"`)) },
+			want: `WARN  slow path="/a b" empty="" err="say \"hi\""`,
+		},
+		{
+			name: "groups become dotted keys",
+			log: func(l *slog.Logger) {
+				l.WithGroup("http").With("method", "GET").Error("failed", slog.Group("response", "status", 500))
+			},
+			want: "ERROR failed http.method=GET http.response.status=500",
+		},
+		{
+			name: "levels below info are hidden",
+			log:  func(l *slog.Logger) { l.Debug("noisy") },
+			want: "",
+		},
+		{
+			name:  "colors",
+			log:   func(l *slog.Logger) { l.Error("failed", "code", 1) },
+			color: true,
+			want:  ansiRed + "ERROR" + ansiReset + " failed " + ansiCyan + "code=" + ansiReset + "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(NewTextHandler(&buf, nil, tt.color)))
+
+			got := buf.String()
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("output = %q, want nothing", got)
+				}
+				return
+			}
+			// Drop the timestamp, it is checked separately
+			if _, rest, ok := strings.Cut(got, " "); ok {
+				_, rest, _ = strings.Cut(rest, " ")
+				got = strings.TrimSpace(rest)
+			}
+			if tt.color {
+				got = strings.TrimPrefix(got, ansiReset+" ")
+			}
+			if got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextHandler_Timestamp(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewTextHandler(&buf, nil, false)
+
+	r := slog.NewRecord(time.Date(2024, 6, 1, 12, 30, 45, 123e6, time.UTC), slog.LevelInfo, "tick", 0)
+	r.AddAttrs(slog.Time("at", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+	if err := h.Handle(t.Context(), r); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	want := "2024-06-01 12:30:45.123 INFO  tick at=2024-06-01T00:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log output formats
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatCommon   = "common"
+	AccessLogFormatCombined = "combined"
+)
+
+// AccessLogFields lists every field the access log can record, in output order
+var AccessLogFields = []string{
+	"method", "route", "path", "proto", "status", "latency", "bytes_in", "bytes_out",
+	"remote_ip", "user_agent", "request_id", "identity",
+}
+
+// AccessLogOptions configures the access log middleware
+type AccessLogOptions struct {
+	// Logger receives one record per request in the json format
+	Logger *slog.Logger
+	// SampleRate is the fraction of successful requests to log, 5xx responses are always logged
+	SampleRate float64
+	// Fields restricts the json record to the listed fields, all fields are logged if empty
+	Fields []string
+	// Format is one of json, common or combined
+	Format string
+	// Output receives common and combined log lines
+	Output io.Writer
+}
+
+// requestInfoKey is the context key for the per-request requestInfo
+type requestInfoKey struct{}
+
+// requestInfo collects values discovered by inner middleware for the access log
+type requestInfo struct {
+	mu       sync.Mutex
+	identity string
+}
+
+// setIdentity records the authenticated identity for the request if it is being tracked
+func setIdentity(r *http.Request, identity string) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.mu.Lock()
+		info.identity = identity
+		info.mu.Unlock()
+	}
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read counts the bytes read from the wrapped body
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// AccessLogMiddleware creates a middleware that logs one record per request
+func AccessLogMiddleware(opts AccessLogOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Track values set by inner middleware such as the authenticated identity
+			info := &requestInfo{}
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+
+			wrapped := newResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			if !shouldSample(opts.SampleRate, wrapped.statusCode) {
+				return
+			}
+
+			info.mu.Lock()
+			identity := info.identity
+			info.mu.Unlock()
+
+			entry := accessLogEntry{
+				start:     start,
+				latency:   time.Since(start),
+				request:   r,
+				status:    wrapped.statusCode,
+				bytesIn:   body.n,
+				bytesOut:  wrapped.bytesWritten,
+				remoteIP:  ClientIP(r),
+				requestID: RequestIDFromContext(r.Context()),
+				identity:  identity,
+			}
+
+			switch opts.Format {
+			case AccessLogFormatCommon, AccessLogFormatCombined:
+				_, _ = io.WriteString(opts.Output, entry.clf(opts.Format == AccessLogFormatCombined))
+			default:
+				opts.Logger.LogAttrs(r.Context(), slog.LevelInfo, "request", entry.attrs(opts.Fields)...)
+			}
+		})
+	}
+}
+
+// shouldSample decides whether a request is logged based on the sample rate
+func shouldSample(rate float64, status int) bool {
+	if status >= http.StatusInternalServerError || rate >= 1 {
+		return true
+	}
+	return rate > 0 && rand.Float64() < rate
+}
+
+// accessLogEntry holds the values recorded for a single request
+type accessLogEntry struct {
+	start     time.Time
+	latency   time.Duration
+	request   *http.Request
+	status    int
+	bytesIn   int64
+	bytesOut  int64
+	remoteIP  string
+	requestID string
+	identity  string
+}
+
+// attrs returns the slog attributes for the entry limited to the allowed fields
+func (e accessLogEntry) attrs(fields []string) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(AccessLogFields))
+	for _, field := range AccessLogFields {
+		if len(fields) > 0 && !slices.Contains(fields, field) {
+			continue
+		}
+
+		switch field {
+		case "method":
+			attrs = append(attrs, slog.String(field, e.request.Method))
+		case "route":
+			attrs = append(attrs, slog.String(field, e.request.Pattern))
+		case "path":
+			attrs = append(attrs, slog.String(field, e.request.URL.Path))
+		case "proto":
+			attrs = append(attrs, slog.String(field, e.request.Proto))
+		case "status":
+			attrs = append(attrs, slog.Int(field, e.status))
+		case "latency":
+			attrs = append(attrs, slog.Duration(field, e.latency))
+		case "bytes_in":
+			attrs = append(attrs, slog.Int64(field, e.bytesIn))
+		case "bytes_out":
+			attrs = append(attrs, slog.Int64(field, e.bytesOut))
+		case "remote_ip":
+			attrs = append(attrs, slog.String(field, e.remoteIP))
+		case "user_agent":
+			attrs = append(attrs, slog.String(field, e.request.UserAgent()))
+		case "request_id":
+			attrs = append(attrs, slog.String(field, e.requestID))
+		case "identity":
+			attrs = append(attrs, slog.String(field, e.identity))
+		}
+	}
+	return attrs
+}
+
+// clf formats the entry in Common Log Format, or Combined Log Format when combined is true
+func (e accessLogEntry) clf(combined bool) string {
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s",
+		e.remoteIP,
+		clfValue(e.identity),
+		e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(fmt.Sprintf("%s %s %s", e.request.Method, e.request.RequestURI, e.request.Proto)),
+		e.status,
+		clfBytes(e.bytesOut),
+	)
+	if combined {
+		line += fmt.Sprintf(" %s %s", strconv.Quote(clfValue(e.request.Referer())), strconv.Quote(clfValue(e.request.UserAgent())))
+	}
+	return line + "\n"
+}
+
+// clfValue returns "-" for empty values as Common Log Format requires
+func clfValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+// clfBytes returns the response size or "-" when no body was sent
+func clfBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
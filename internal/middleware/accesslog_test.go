@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogMiddleware_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+
+	handler := RequestIDMiddleware(RequestIDOptions{})(
+		AccessLogMiddleware(AccessLogOptions{Logger: logger, SampleRate: 1})(
+			AuthMiddleware(newMockValidator("valid-key"), []string{"/items/"})(mux),
+		),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/items/42", strings.NewReader("hello"))
+	req.Header.Set("X-API-Key", "valid-key")
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode log record %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"msg":        "request",
+		"method":     http.MethodPost,
+		"route":      "POST /items/{id}",
+		"path":       "/items/42",
+		"proto":      "HTTP/1.1",
+		"status":     float64(http.StatusCreated),
+		"bytes_in":   float64(5),
+		"bytes_out":  float64(5),
+		"remote_ip":  "192.0.2.1",
+		"user_agent": "test-agent",
+		"request_id": "req-1",
+		"identity":   "apikey:" + KeyID("valid-key"),
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s = %v, want %v", k, record[k], v)
+		}
+	}
+
+	if _, ok := record["latency"]; !ok {
+		t.Error("latency missing from log record")
+	}
+}
+
+func TestAccessLogMiddleware_FieldAllowlist(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := AccessLogMiddleware(AccessLogOptions{
+		Logger:     logger,
+		SampleRate: 1,
+		Fields:     []string{"method", "status"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode log record: %v", err)
+	}
+
+	for _, field := range AccessLogFields {
+		_, present := record[field]
+		wantPresent := field == "method" || field == "status"
+		if present != wantPresent {
+			t.Errorf("field %s present = %v, want %v", field, present, wantPresent)
+		}
+	}
+}
+
+func TestAccessLogMiddleware_Sampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		status     int
+		wantLogged bool
+	}{
+		{
+			name:       "full rate logs success",
+			sampleRate: 1,
+			status:     http.StatusOK,
+			wantLogged: true,
+		},
+		{
+			name:       "zero rate skips success",
+			sampleRate: 0,
+			status:     http.StatusOK,
+			wantLogged: false,
+		},
+		{
+			name:       "zero rate still logs server errors",
+			sampleRate: 0,
+			status:     http.StatusInternalServerError,
+			wantLogged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			handler := AccessLogMiddleware(AccessLogOptions{Logger: logger, SampleRate: tt.sampleRate})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+				}),
+			)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if logged := buf.Len() > 0; logged != tt.wantLogged {
+				t.Errorf("logged = %v, want %v", logged, tt.wantLogged)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware_CLF(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		pattern string
+	}{
+		{
+			name:    "common log format",
+			format:  AccessLogFormatCommon,
+			pattern: `^192\.0\.2\.1 - - \[[^\]]+\] "GET /clf\?x=1 HTTP/1\.1" 200 2\n$`,
+		},
+		{
+			name:    "combined log format",
+			format:  AccessLogFormatCombined,
+			pattern: `^192\.0\.2\.1 - - \[[^\]]+\] "GET /clf\?x=1 HTTP/1\.1" 200 2 "https://example\.com/" "curl/8\.0"\n$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			handler := AccessLogMiddleware(AccessLogOptions{SampleRate: 1, Format: tt.format, Output: &out})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte("ok"))
+				}),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/clf?x=1", nil)
+			req.Header.Set("Referer", "https://example.com/")
+			req.Header.Set("User-Agent", "curl/8.0")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !regexp.MustCompile(tt.pattern).MatchString(out.String()) {
+				t.Errorf("log line = %q, want match for %s", out.String(), tt.pattern)
+			}
+		})
+	}
+}
@@ -1,8 +1,9 @@
 package middleware
 
 import (
+	"crypto/sha256"
 	"crypto/subtle"
-	"encoding/json"
+	"encoding/hex"
 	"net/http"
 	"strings"
 )
@@ -12,11 +13,6 @@ type APIKeyValidator interface {
 	ValidateAPIKey(key string) bool
 }
 
-// authErrorResponse represents an authentication error response
-type authErrorResponse struct {
-	Error string `json:"error"`
-}
-
 // AuthMiddleware creates a middleware that validates API keys
 // Protected paths require a valid API key in the X-API-Key header
 func AuthMiddleware(validator APIKeyValidator, protectedPrefixes []string) func(http.Handler) http.Handler {
@@ -31,21 +27,67 @@ func AuthMiddleware(validator APIKeyValidator, protectedPrefixes []string) func(
 			// Get API key from header
 			apiKey := r.Header.Get("X-API-Key")
 			if apiKey == "" {
-				writeAuthError(w, http.StatusUnauthorized, "missing API key")
+				WriteError(w, http.StatusUnauthorized, "missing API key")
 				return
 			}
 
 			// Validate the API key
 			if !validator.ValidateAPIKey(apiKey) {
-				writeAuthError(w, http.StatusUnauthorized, "invalid API key")
+				WriteError(w, http.StatusUnauthorized, "invalid API key")
 				return
 			}
 
+			setIdentity(r, "apikey:"+KeyID(apiKey))
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// OperationalCredentials holds the credentials that guard operational endpoints such as /metrics
+// A request is accepted if it presents either the bearer token or the basic auth pair
+// Basic auth with an empty Password never succeeds, so a half-configured pair fails closed
+type OperationalCredentials struct {
+	Token    string
+	Username string
+	Password string
+}
+
+// enabled reports whether any credentials are configured
+func (c OperationalCredentials) enabled() bool {
+	return c.Token != "" || c.Username != ""
+}
+
+// OperationalAuthMiddleware creates a middleware that requires a bearer token or basic auth
+// When no credentials are configured every request is passed through
+func OperationalAuthMiddleware(creds OperationalCredentials) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !creds.enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if creds.Token != "" {
+				if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && SecureCompare(token, creds.Token) {
+					setIdentity(r, "token")
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if creds.Username != "" {
+				if user, pass, ok := r.BasicAuth(); ok && creds.Password != "" && SecureCompare(user, creds.Username) && SecureCompare(pass, creds.Password) {
+					setIdentity(r, "basic:"+user)
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="operational"`)
+			}
+
+			WriteError(w, http.StatusUnauthorized, "invalid credentials")
+		})
+	}
+}
+
 // isProtectedPath checks if the given path matches any protected prefix
 func isProtectedPath(path string, protectedPrefixes []string) bool {
 	for _, prefix := range protectedPrefixes {
@@ -56,15 +98,14 @@ func isProtectedPath(path string, protectedPrefixes []string) bool {
 	return false
 }
 
-// writeAuthError writes a JSON error response for authentication failures
-func writeAuthError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(authErrorResponse{Error: message})
-}
-
 // SecureCompare performs a constant-time comparison of two strings
 // This prevents timing attacks when comparing API keys
 func SecureCompare(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
+
+// KeyID returns a short, non-reversible identifier for an API key that is safe to log
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
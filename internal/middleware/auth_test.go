@@ -135,7 +135,7 @@ func TestAuthMiddleware(t *testing.T) {
 			}
 
 			if tt.wantError != "" {
-				var errResp authErrorResponse
+				var errResp ErrorResponse
 				if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
 					t.Fatalf("failed to decode error response: %v", err)
 				}
@@ -258,3 +258,109 @@ func TestSecureCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestOperationalAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		creds         OperationalCredentials
+		setupRequest  func(r *http.Request)
+		wantStatus    int
+		wantChallenge bool
+	}{
+		{
+			name:         "no credentials configured passes through",
+			creds:        OperationalCredentials{},
+			setupRequest: func(r *http.Request) {},
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:  "valid bearer token",
+			creds: OperationalCredentials{Token: "scrape-token"},
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer scrape-token")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "invalid bearer token",
+			creds: OperationalCredentials{Token: "scrape-token"},
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer wrong")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "missing bearer token",
+			creds:        OperationalCredentials{Token: "scrape-token"},
+			setupRequest: func(r *http.Request) {},
+			wantStatus:   http.StatusUnauthorized,
+		},
+		{
+			name:  "valid basic auth",
+			creds: OperationalCredentials{Username: "prom", Password: "secret"},
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("prom", "secret")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "invalid basic auth password",
+			creds: OperationalCredentials{Username: "prom", Password: "secret"},
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("prom", "wrong")
+			},
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: true,
+		},
+		{
+			name:  "basic auth rejected without a configured password",
+			creds: OperationalCredentials{Username: "prom"},
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("prom", "")
+			},
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: true,
+		},
+		{
+			name:  "basic auth accepted when token also configured",
+			creds: OperationalCredentials{Token: "scrape-token", Username: "prom", Password: "secret"},
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("prom", "secret")
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := OperationalAuthMiddleware(tt.creds)(nextHandler)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tt.setupRequest(req)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate present = %v, want %v", got, tt.wantChallenge)
+			}
+
+			if tt.wantStatus == http.StatusUnauthorized {
+				var errResp ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode error response: %v", err)
+				}
+				if errResp.Error != "invalid credentials" {
+					t.Errorf("error = %q, want %q", errResp.Error, "invalid credentials")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	opts := BodyLimitOptions{
+		MaxBytes:      10,
+		RouteMaxBytes: map[string]int64{"POST /big": 100, "POST /unlimited": 0},
+		RouteContentTypes: map[string][]string{
+			"POST /json": {"application/json", "text/*"},
+		},
+		Pattern: func(r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		},
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		body          string
+		contentType   string
+		hideLength    bool
+		wantStatus    int
+		wantRejection string
+	}{
+		{
+			name:       "body within global limit",
+			path:       "/small",
+			body:       "0123456789",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "declared body over global limit",
+			path:          "/small",
+			body:          "0123456789A",
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantRejection: RejectBodyTooLarge,
+		},
+		{
+			name:          "streamed body over global limit",
+			path:          "/small",
+			body:          "0123456789A",
+			hideLength:    true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantRejection: RejectBodyTooLarge,
+		},
+		{
+			name:       "route limit raises global limit",
+			path:       "/big",
+			body:       strings.Repeat("a", 50),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "route limit of zero is unlimited",
+			path:       "/unlimited",
+			body:       strings.Repeat("a", 500),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "allowed content type with parameters",
+			path:        "/json",
+			body:        "{}",
+			contentType: "application/json; charset=utf-8",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "wildcard content type",
+			path:        "/json",
+			body:        "hi",
+			contentType: "text/plain",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:          "disallowed content type",
+			path:          "/json",
+			body:          "<x/>",
+			contentType:   "application/xml",
+			wantStatus:    http.StatusUnsupportedMediaType,
+			wantRejection: RejectUnsupportedMediaType,
+		},
+		{
+			name:          "missing content type",
+			path:          "/json",
+			body:          "{}",
+			wantStatus:    http.StatusUnsupportedMediaType,
+			wantRejection: RejectUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_rejections_total"}, []string{"reason"})
+			opts := opts
+			opts.Rejections = rejections
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := BodyLimitMiddleware(opts)(next)
+
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.hideLength {
+				// Wrapping hides the length so the request is sent as a stream
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			if tt.hideLength {
+				req.ContentLength = -1
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				var errResp ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+					t.Errorf("expected JSON error body, got %q (err %v)", rec.Body.String(), err)
+				}
+			}
+
+			for _, reason := range []string{RejectBodyTooLarge, RejectUnsupportedMediaType} {
+				want := 0.0
+				if reason == tt.wantRejection {
+					want = 1
+				}
+				if got := testutil.ToFloat64(rejections.WithLabelValues(reason)); got != want {
+					t.Errorf("rejections{reason=%q} = %v, want %v", reason, got, want)
+				}
+			}
+		})
+	}
+}
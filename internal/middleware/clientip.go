@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPOptions configures client IP resolution
+type ClientIPOptions struct {
+	// TrustedProxies lists the proxies whose Forwarded and X-Forwarded-For headers are honored
+	TrustedProxies []netip.Prefix
+}
+
+// clientIPKey is the context key for the resolved client IP
+type clientIPKey struct{}
+
+// ClientIP returns the client IP resolved by ClientIPMiddleware, or the host of r.RemoteAddr without it
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return hostOnly(r.RemoteAddr)
+}
+
+// ClientIPMiddleware creates a middleware that resolves the client IP once per request
+// Forwarding headers are only honored when the direct peer is a trusted proxy, and the chain is
+// walked from the right so hops added by untrusted clients cannot spoof the result
+func ClientIPMiddleware(opts ClientIPOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := opts.resolve(r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// resolve returns the first untrusted address in the forwarding chain, starting from the peer
+func (o ClientIPOptions) resolve(r *http.Request) string {
+	peer := hostOnly(r.RemoteAddr)
+	if !o.trusted(peer) {
+		return peer
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		hops = xForwardedFor(r.Header)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Obfuscated or malformed hops end the chain, the last trusted hop is the best answer
+			break
+		}
+		client = addr.Unmap().String()
+		if !o.trusted(client) {
+			break
+		}
+	}
+	return client
+}
+
+// trusted reports whether ip falls in a trusted proxy range
+func (o ClientIPOptions) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range o.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor extracts the for= values of RFC 7239 Forwarded headers in order
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, value := range h.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(k, "for") {
+					continue
+				}
+				hops = append(hops, forwardedNode(v))
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedNode strips quotes, brackets and port from a Forwarded node such as "[2001:db8::1]:4711"
+func forwardedNode(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), `"`)
+	if strings.HasPrefix(v, "[") {
+		if end := strings.Index(v, "]"); end > 0 {
+			return v[1:end]
+		}
+		return v
+	}
+	if host, _, err := net.SplitHostPort(v); err == nil {
+		return host
+	}
+	return v
+}
+
+// xForwardedFor extracts the addresses of X-Forwarded-For headers in order
+func xForwardedFor(h http.Header) []string {
+	var hops []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// hostOnly returns the host part of a host:port address, or the address unchanged
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
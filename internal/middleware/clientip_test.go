@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	opts := ClientIPOptions{TrustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:5000",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof",
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy x-forwarded-for",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed leftmost hop ignored",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1, 10.0.0.3"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "multiple x-forwarded-for headers",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1", "10.0.0.3"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.4, 10.0.0.3"}},
+			want:       "10.0.0.4",
+		},
+		{
+			name:       "forwarded header preferred",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=https, for="[2001:db8:ffff::1]:4711"`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "192.0.2.60",
+		},
+		{
+			name:       "forwarded ipv6 client",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"Forwarded": {`for="[2001:db8::17]:4711"`}},
+			want:       "2001:db8::17",
+		},
+		{
+			name:       "obfuscated hop stops the walk",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string][]string{"Forwarded": {"for=192.0.2.60, for=_hidden, for=10.0.0.3"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "ipv4-mapped peer",
+			remoteAddr: "[::ffff:10.0.0.2]:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIPMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, values := range tt.headers {
+				for _, v := range values {
+					req.Header.Add(k, v)
+				}
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP_WithoutMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	if got := ClientIP(req); got != "192.0.2.1" {
+		t.Errorf("ClientIP() = %q, want %q", got, "192.0.2.1")
+	}
+}
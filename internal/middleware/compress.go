@@ -0,0 +1,370 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Supported content codings
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+)
+
+// DefaultCompressionEncodings is the server preference order used when none is configured
+var DefaultCompressionEncodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+// CompressionOptions configures the response compression middleware
+type CompressionOptions struct {
+	// MinSize is the smallest response body in bytes that is compressed
+	MinSize int
+	// ContentTypes lists compressible media types, "type/*" matches any subtype
+	ContentTypes []string
+	// Encodings lists the enabled encodings in server preference order
+	Encodings []string
+}
+
+// encoder is the common interface of the pooled compressors
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// zstdEncoder adapts zstd.Encoder to the encoder interface
+type zstdEncoder struct {
+	*zstd.Encoder
+}
+
+// Reset points the zstd encoder at a new writer
+func (z zstdEncoder) Reset(w io.Writer) {
+	z.Encoder.Reset(w)
+}
+
+// encoderPools holds a pool of reusable compressors per encoding
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	EncodingZstd: {New: func() any {
+		// A single goroutine and small window keep per-response memory low
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+		return zstdEncoder{enc}
+	}},
+	EncodingBrotli: {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}},
+}
+
+// CompressionMiddleware creates a middleware that compresses responses based on Accept-Encoding
+func CompressionMiddleware(opts CompressionOptions) func(http.Handler) http.Handler {
+	encodings := slices.DeleteFunc(slices.Clone(opts.Encodings), func(enc string) bool {
+		return !slices.Contains(DefaultCompressionEncodings, enc)
+	})
+	if len(encodings) == 0 {
+		encodings = DefaultCompressionEncodings
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				opts:           opts,
+				statusCode:     http.StatusOK,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the supported encoding with the highest q-value, ties go to server preference
+func negotiateEncoding(acceptEncoding string, preference []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range preference {
+		q, ok := quality[enc]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it can decide whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	opts     CompressionOptions
+
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         encoder
+}
+
+// WriteHeader records the status, the real header is sent once the compression decision is made
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	// Informational responses pass straight through
+	if code >= 100 && code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.statusCode = code
+	cw.wroteHeader = true
+}
+
+// Write buffers until MinSize bytes are available, then streams through the chosen path
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		return cw.write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) < cw.opts.MinSize {
+		return len(b), nil
+	}
+
+	if err := cw.decide(false); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// write sends b through the encoder when compressing, or directly otherwise
+func (cw *compressWriter) write(b []byte) (int, error) {
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// decide chooses between compressed and plain output, sends the header and flushes the buffer
+// Streaming responses are compressed regardless of how much has been buffered so far
+func (cw *compressWriter) decide(streaming bool) error {
+	cw.decided = true
+
+	if cw.shouldCompress(streaming) {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.write(buf)
+	return err
+}
+
+// shouldCompress applies the size, status, existing encoding and content type rules
+func (cw *compressWriter) shouldCompress(streaming bool) bool {
+	if !streaming && (len(cw.buf) == 0 || len(cw.buf) < cw.opts.MinSize) {
+		return false
+	}
+	if cw.statusCode < http.StatusOK || cw.statusCode == http.StatusNoContent || cw.statusCode == http.StatusNotModified {
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+	}
+	return mediaTypeMatches(contentType, cw.opts.ContentTypes)
+}
+
+// Flush sends any buffered data and flushes the encoder and the underlying writer
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			cw.WriteHeader(http.StatusOK)
+		}
+		// Flushing early means the response is streamed, so the size threshold no longer applies
+		_ = cw.decide(true)
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack lets protocol upgrades take over the connection
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the response, writing small buffered bodies uncompressed and returning encoders to the pool
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// Handlers that never wrote a status get the implicit 200
+		if !cw.wroteHeader && len(cw.buf) == 0 {
+			return
+		}
+		_ = cw.decide(false)
+	}
+
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(io.Discard)
+		encoderPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// DecompressOptions configures request body decompression
+type DecompressOptions struct {
+	// MaxBytes limits the decompressed body size, 0 means unlimited
+	MaxBytes int64
+	// Rejections counts decompressed bodies over MaxBytes as body_too_large, ignored if nil
+	Rejections *prometheus.CounterVec
+}
+
+// errUnsupportedEncoding is returned for request bodies in an unknown content coding
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// DecompressMiddleware creates a middleware that transparently decodes gzip and zstd request bodies
+// Unknown encodings are rejected with 415 and decoded bodies are capped at MaxBytes
+func DecompressMiddleware(opts DecompressOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// raw notes when the compressed body itself hit an outer limit, which counts that rejection already
+			raw := &limitedBody{ReadCloser: r.Body}
+			body, err := newDecoder(encoding, raw, opts.MaxBytes)
+			if err != nil {
+				if errors.Is(err, errUnsupportedEncoding) {
+					w.Header().Set("Accept-Encoding", "gzip, zstd")
+					WriteError(w, http.StatusUnsupportedMediaType, "unsupported content encoding: "+encoding)
+					return
+				}
+				WriteError(w, http.StatusBadRequest, "invalid "+encoding+" body: "+err.Error())
+				return
+			}
+			defer body.Close()
+
+			decoded := &limitedBody{ReadCloser: body}
+			if opts.MaxBytes > 0 {
+				decoded.ReadCloser = http.MaxBytesReader(w, body, opts.MaxBytes)
+			}
+			r.Body = decoded
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+
+			next.ServeHTTP(w, r)
+
+			if decoded.exceeded && !raw.exceeded && opts.Rejections != nil {
+				opts.Rejections.WithLabelValues(RejectBodyTooLarge).Inc()
+			}
+		})
+	}
+}
+
+// newDecoder returns a reader that decodes body according to encoding
+// maxBytes also caps the zstd window so a small frame cannot make the decoder allocate more than the body limit
+func newDecoder(encoding string, body io.ReadCloser, maxBytes int64) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		return gzip.NewReader(body)
+	case EncodingZstd:
+		zopts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if maxBytes > 0 {
+			limit := uint64(max(maxBytes, zstd.MinWindowSize))
+			zopts = append(zopts, zstd.WithDecoderMaxWindow(limit), zstd.WithDecoderMaxMemory(limit))
+		}
+		dec, err := zstd.NewReader(body, zopts...)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdReader{ReadCloser: dec.IOReadCloser(), limit: maxBytes}, nil
+	default:
+		return nil, errUnsupportedEncoding
+	}
+}
+
+// zstdReader reports frames that exceed the decoder limits as an oversized body
+type zstdReader struct {
+	io.ReadCloser
+	limit int64
+}
+
+// Read decodes from the underlying reader, mapping window and size limit errors to *http.MaxBytesError
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.ReadCloser.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		err = &http.MaxBytesError{Limit: z.limit}
+	}
+	return n, err
+}
@@ -0,0 +1,354 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	preference := []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "empty header", acceptEncoding: "", want: ""},
+		{name: "gzip only", acceptEncoding: "gzip", want: EncodingGzip},
+		{name: "server preference breaks ties", acceptEncoding: "gzip, br, zstd", want: EncodingZstd},
+		{name: "client q-values win", acceptEncoding: "gzip;q=1.0, zstd;q=0.5", want: EncodingGzip},
+		{name: "q=0 excludes encoding", acceptEncoding: "zstd;q=0, br", want: EncodingBrotli},
+		{name: "wildcard", acceptEncoding: "*", want: EncodingZstd},
+		{name: "identity only", acceptEncoding: "identity", want: ""},
+		{name: "unsupported only", acceptEncoding: "deflate", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding, preference); got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
+
+// decode reverses the given content coding
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip.NewReader: %v", err)
+		}
+		r = gr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("zstd.NewReader: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s body: %v", encoding, err)
+	}
+	return string(out)
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	opts := CompressionOptions{
+		MinSize:      64,
+		ContentTypes: []string{"application/json", "text/*"},
+	}
+	large := `{"data":"` + strings.Repeat("echo ", 100) + `"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", status: http.StatusOK, body: large, wantEncoding: EncodingGzip},
+		{name: "zstd", acceptEncoding: "zstd", contentType: "application/json", status: http.StatusOK, body: large, wantEncoding: EncodingZstd},
+		{name: "brotli", acceptEncoding: "br", contentType: "application/json", status: http.StatusOK, body: large, wantEncoding: EncodingBrotli},
+		{name: "wildcard content type", acceptEncoding: "gzip", contentType: "text/plain; charset=utf-8", status: http.StatusOK, body: large, wantEncoding: EncodingGzip},
+		{name: "below minimum size", acceptEncoding: "gzip", contentType: "application/json", status: http.StatusOK, body: `{"small":true}`},
+		{name: "content type not allowed", acceptEncoding: "gzip", contentType: "image/png", status: http.StatusOK, body: large},
+		{name: "no accept encoding", contentType: "application/json", status: http.StatusOK, body: large},
+		{name: "error status still compressed", acceptEncoding: "gzip", contentType: "application/json", status: http.StatusBadRequest, body: large, wantEncoding: EncodingGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressionMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				// Write in chunks to exercise buffering across calls
+				for chunk := range strings.SplitAfterSeq(tt.body, " ") {
+					_, _ = w.Write([]byte(chunk))
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+
+			if got := decode(t, tt.wantEncoding, rec.Body.Bytes()); got != tt.body {
+				t.Errorf("decoded body length = %d, want %d", len(got), len(tt.body))
+			}
+
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want %q", got, "Accept-Encoding")
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware_CapturesStatusThroughMetrics(t *testing.T) {
+	m := NewMetrics(prometheus.NewRegistry(), MetricsOptions{})
+
+	handler := CompressionMiddleware(CompressionOptions{ContentTypes: []string{"text/*"}})(
+		MetricsMiddleware(m)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(strings.Repeat("created ", 50)))
+		})),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/things", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	if got := testutil.ToFloat64(m.EndpointCount.WithLabelValues("/things", http.MethodPost, "201")); got != 1 {
+		t.Errorf("http_request_total{status=201} = %v, want 1", got)
+	}
+}
+
+func TestCompressionMiddleware_Flush(t *testing.T) {
+	handler := CompressionMiddleware(CompressionOptions{MinSize: 1024, ContentTypes: []string{"text/*"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: 1\n\n"))
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("Flush() error = %v", err)
+			}
+			_, _ = w.Write([]byte("data: 2\n\n"))
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+
+	// Streaming responses are compressed even below the size threshold
+	if got := decode(t, rec.Header().Get("Content-Encoding"), rec.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+// compress encodes body with the given content coding
+func compress(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch encoding {
+	case EncodingGzip:
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(body))
+		_ = w.Close()
+	case EncodingZstd:
+		w, _ := zstd.NewWriter(&buf)
+		_, _ = w.Write([]byte(body))
+		_ = w.Close()
+	default:
+		buf.WriteString(body)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		maxBytes        int64
+		wantStatus      int
+		wantBody        string
+		wantRejected    float64
+	}{
+		{
+			name:       "plain body untouched",
+			body:       []byte("plain"),
+			wantStatus: http.StatusOK,
+			wantBody:   "plain",
+		},
+		{
+			name:            "gzip body decoded",
+			contentEncoding: "gzip",
+			body:            compress(t, EncodingGzip, "hello gzip"),
+			wantStatus:      http.StatusOK,
+			wantBody:        "hello gzip",
+		},
+		{
+			name:            "zstd body decoded",
+			contentEncoding: "zstd",
+			body:            compress(t, EncodingZstd, "hello zstd"),
+			wantStatus:      http.StatusOK,
+			wantBody:        "hello zstd",
+		},
+		{
+			name:            "unsupported encoding",
+			contentEncoding: "br",
+			body:            []byte("whatever"),
+			wantStatus:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:            "corrupt gzip body",
+			contentEncoding: "gzip",
+			body:            []byte("not gzip"),
+			wantStatus:      http.StatusBadRequest,
+		},
+		{
+			// An empty frame whose header declares a 1 GiB window
+			name:            "zstd window over limit",
+			contentEncoding: "zstd",
+			body:            []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0xa0, 0x01, 0x00, 0x00},
+			maxBytes:        1 << 20,
+			wantStatus:      http.StatusRequestEntityTooLarge,
+			wantRejected:    1,
+		},
+		{
+			name:            "zstd window within limit",
+			contentEncoding: "zstd",
+			body:            []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x00, 0x01, 0x00, 0x00},
+			maxBytes:        1 << 20,
+			wantStatus:      http.StatusOK,
+		},
+		{
+			name:            "decoded body over limit",
+			contentEncoding: "gzip",
+			body:            compress(t, EncodingGzip, strings.Repeat("a", 1000)),
+			maxBytes:        100,
+			wantStatus:      http.StatusRequestEntityTooLarge,
+			wantRejected:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_rejections_total"}, []string{"reason"})
+			handler := DecompressMiddleware(DecompressOptions{MaxBytes: tt.maxBytes, Rejections: rejections})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.Header.Get("Content-Encoding") != "" {
+						t.Error("Content-Encoding should be removed after decoding")
+					}
+					readBody(w, r)
+				}),
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", bytes.NewReader(tt.body))
+			if tt.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := testutil.ToFloat64(rejections.WithLabelValues(RejectBodyTooLarge)); got != tt.wantRejected {
+				t.Errorf("body_too_large rejections = %v, want %v", got, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestDecompressMiddleware_OuterLimitCountedOnce(t *testing.T) {
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_rejections_total"}, []string{"reason"})
+	inner := DecompressMiddleware(DecompressOptions{MaxBytes: 64, Rejections: rejections})(http.HandlerFunc(readBody))
+	handler := BodyLimitMiddleware(BodyLimitOptions{MaxBytes: 64, Rejections: rejections})(inner)
+
+	// Random data does not compress, so the compressed body itself goes over the outer limit
+	data := make([]byte, 1024)
+	_, _ = rand.Read(data)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", bytes.NewReader(compress(t, EncodingGzip, string(data))))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if got := testutil.ToFloat64(rejections.WithLabelValues(RejectBodyTooLarge)); got != 1 {
+		t.Errorf("body_too_large rejections = %v, want 1", got)
+	}
+}
+
+// readBody echoes the request body, answering 413 when it is over a limit and 400 when it cannot be read
+func readBody(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	_, _ = w.Write(body)
+}
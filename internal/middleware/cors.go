@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures the CORS middleware
+type CORSOptions struct {
+	// AllowedOrigins lists allowed origins, "*" allows any and "https://*.example.com" matches subdomains
+	AllowedOrigins []string
+	// AllowedMethods lists the methods returned on preflight responses
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed on preflight, "*" reflects the requested headers
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers readable by browser scripts
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers on cross-origin requests
+	AllowCredentials bool
+	// MaxAge is how long in seconds browsers may cache preflight results, 0 omits the header
+	MaxAge int
+}
+
+// CORSMiddleware creates a middleware that sets CORS headers and answers preflight requests
+// Preflights are answered directly so they never reach authentication or the ServeMux
+func CORSMiddleware(opts CORSOptions) func(http.Handler) http.Handler {
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !opts.originAllowed(origin) {
+				if preflight {
+					WriteError(w, http.StatusForbidden, "origin not allowed")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Credentialed requests cannot use the "*" wildcard, so the origin is echoed instead
+			allowOrigin := origin
+			if slices.Contains(opts.AllowedOrigins, "*") && !opts.AllowCredentials {
+				allowOrigin = "*"
+			}
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			if opts.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if methods != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+			}
+			allowHeaders := headers
+			if slices.Contains(opts.AllowedHeaders, "*") {
+				allowHeaders = r.Header.Get("Access-Control-Request-Headers")
+			}
+			if allowHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// originAllowed reports whether the origin matches any allowed origin pattern
+func (o CORSOptions) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if strings.Contains(allowed, "*") {
+			if ok, _ := path.Match(allowed, origin); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
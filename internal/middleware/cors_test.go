@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	baseOpts := CORSOptions{
+		AllowedOrigins: []string{"https://dash.example.com", "https://*.tools.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         600,
+	}
+
+	tests := []struct {
+		name           string
+		opts           CORSOptions
+		method         string
+		origin         string
+		requestMethod  string
+		requestHeaders string
+		wantStatus     int
+		wantNextCalled bool
+		wantHeaders    map[string]string
+	}{
+		{
+			name:           "no origin passes through untouched",
+			opts:           baseOpts,
+			method:         http.MethodGet,
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:           "allowed origin on simple request",
+			opts:           baseOpts,
+			method:         http.MethodPost,
+			origin:         "https://dash.example.com",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://dash.example.com",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+			},
+		},
+		{
+			name:           "wildcard subdomain origin",
+			opts:           baseOpts,
+			method:         http.MethodGet,
+			origin:         "https://a.tools.example.com",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Origin": "https://a.tools.example.com"},
+		},
+		{
+			name:           "disallowed origin gets no CORS headers",
+			opts:           baseOpts,
+			method:         http.MethodGet,
+			origin:         "https://evil.example.org",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:          "preflight answered without calling next",
+			opts:          baseOpts,
+			method:        http.MethodOptions,
+			origin:        "https://dash.example.com",
+			requestMethod: http.MethodPost,
+			wantStatus:    http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://dash.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type, X-API-Key",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:          "preflight from disallowed origin",
+			opts:          baseOpts,
+			method:        http.MethodOptions,
+			origin:        "https://evil.example.org",
+			requestMethod: http.MethodPost,
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:           "any origin without credentials uses star",
+			opts:           CORSOptions{AllowedOrigins: []string{"*"}},
+			method:         http.MethodGet,
+			origin:         "https://anything.test",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:           "any origin with credentials echoes origin",
+			opts:           CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:         http.MethodGet,
+			origin:         "https://anything.test",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://anything.test",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:           "wildcard headers reflect requested headers",
+			opts:           CORSOptions{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}},
+			method:         http.MethodOptions,
+			origin:         "https://anything.test",
+			requestMethod:  http.MethodPut,
+			requestHeaders: "X-Custom, Content-Type",
+			wantStatus:     http.StatusNoContent,
+			wantHeaders:    map[string]string{"Access-Control-Allow-Headers": "X-Custom, Content-Type"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := CORSMiddleware(tt.opts)(next)
+
+			req := httptest.NewRequest(tt.method, "/api/v1/echo", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			if tt.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if nextCalled != tt.wantNextCalled {
+				t.Errorf("next handler called = %v, want %v", nextCalled, tt.wantNextCalled)
+			}
+
+			for k, v := range tt.wantHeaders {
+				if got := rec.Header().Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
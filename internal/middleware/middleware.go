@@ -1,36 +1,130 @@
 package middleware
 
 import (
+	"encoding/json"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	RequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Duration of HTTP requests.",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"path", "method", "status"},
-	)
-
-	EndpointCount = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_request_total",
-			Help: "Total number of HTTP requests.",
-		},
-		[]string{"path", "method", "status"},
-	)
-)
+// Metrics holds the prometheus collectors recorded by the middleware
+type Metrics struct {
+	RequestDuration *prometheus.HistogramVec
+	EndpointCount   *prometheus.CounterVec
+	PanicCount      prometheus.Counter
+	Rejections      *prometheus.CounterVec
+}
+
+// MetricsOptions configures the request duration histogram
+type MetricsOptions struct {
+	// Buckets are the classic histogram upper bounds in seconds, prometheus.DefBuckets if empty
+	Buckets []float64
+	// NativeHistogramBucketFactor enables native histograms when greater than 1
+	NativeHistogramBucketFactor float64
+	// NativeHistogramMaxBucketNumber limits the native histogram bucket count, 0 means unlimited
+	NativeHistogramMaxBucketNumber uint32
+}
+
+// histogramBuckets returns sorted, de-duplicated buckets or the prometheus defaults
+func (o MetricsOptions) histogramBuckets() []float64 {
+	if len(o.Buckets) == 0 {
+		return prometheus.DefBuckets
+	}
+
+	// Prometheus panics on buckets that are not strictly increasing
+	buckets := slices.Clone(o.Buckets)
+	slices.Sort(buckets)
+	return slices.Compact(buckets)
+}
+
+// NewMetrics creates the HTTP metrics and registers them with the given registerer
+func NewMetrics(reg prometheus.Registerer, opts MetricsOptions) *Metrics {
+	histogramOpts := prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests.",
+		Buckets: opts.histogramBuckets(),
+	}
+
+	// Native histograms are exposed alongside the classic buckets
+	if opts.NativeHistogramBucketFactor > 1 {
+		histogramOpts.NativeHistogramBucketFactor = opts.NativeHistogramBucketFactor
+		histogramOpts.NativeHistogramMaxBucketNumber = opts.NativeHistogramMaxBucketNumber
+		histogramOpts.NativeHistogramMinResetDuration = time.Hour
+	}
+
+	m := &Metrics{
+		RequestDuration: prometheus.NewHistogramVec(
+			histogramOpts,
+			[]string{"path", "method", "status"},
+		),
+		EndpointCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_request_total",
+				Help: "Total number of HTTP requests.",
+			},
+			[]string{"path", "method", "status"},
+		),
+		PanicCount: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "http_panics_total",
+				Help: "Total number of panics recovered from HTTP handlers.",
+			},
+		),
+		Rejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_request_rejections_total",
+				Help: "Total number of requests rejected before reaching a handler.",
+			},
+			[]string{"reason"},
+		),
+	}
+
+	reg.MustRegister(m.RequestDuration, m.EndpointCount, m.PanicCount, m.Rejections)
+	return m
+}
+
+// ErrorResponse represents a JSON error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// WriteError writes a JSON error response in the project's standard error shape
+func WriteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
+
+// mediaTypeMatches reports whether contentType matches an entry in allowed, "type/*" matches any subtype
+func mediaTypeMatches(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
 
-// responseWriter wraps http.ResponseWriter to capture the status code
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code and response size
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode  int
-	wroteHeader bool
+	statusCode   int
+	wroteHeader  bool
+	bytesWritten int64
 }
 
 // newResponseWriter creates a new responseWriter with default status 200
@@ -51,26 +145,47 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// MetricsMiddleware is the middleware for capturing metrics
-func MetricsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		route := r.URL.Path
-		method := r.Method
+// Write counts the bytes written and marks the implicit 200 status as sent
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
 
-		// Wrap the response writer to capture status code
-		wrapped := newResponseWriter(w)
+// Flush sends buffered data to the client, which also commits the status code
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
 
-		// Start timer for duration metric
-		timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
-			status := strconv.Itoa(wrapped.statusCode)
-			RequestDuration.WithLabelValues(route, method, status).Observe(v)
-		}))
-		defer timer.ObserveDuration()
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// MetricsMiddleware creates a middleware that records request metrics into m
+func MetricsMiddleware(m *Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			method := r.Method
 
-		next.ServeHTTP(wrapped, r)
+			// Wrap the response writer to capture status code
+			wrapped := newResponseWriter(w)
 
-		// Increment the endpoint counter with status code
-		status := strconv.Itoa(wrapped.statusCode)
-		EndpointCount.WithLabelValues(route, method, status).Inc()
-	})
+			// Start timer for duration metric
+			timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+				status := strconv.Itoa(wrapped.statusCode)
+				m.RequestDuration.WithLabelValues(route, method, status).Observe(v)
+			}))
+			defer timer.ObserveDuration()
+
+			next.ServeHTTP(wrapped, r)
+
+			// Increment the endpoint counter with status code
+			status := strconv.Itoa(wrapped.statusCode)
+			m.EndpointCount.WithLabelValues(route, method, status).Inc()
+		})
+	}
 }
@@ -3,7 +3,11 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewResponseWriter(t *testing.T) {
@@ -109,6 +113,24 @@ func TestResponseWriter_WriteHeader_OnlyFirstCall(t *testing.T) {
 	}
 }
 
+func TestResponseWriter_Write(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := newResponseWriter(rec)
+
+	_, _ = rw.Write([]byte("hello "))
+	_, _ = rw.Write([]byte("world"))
+
+	if rw.bytesWritten != 11 {
+		t.Errorf("bytesWritten = %d, want 11", rw.bytesWritten)
+	}
+
+	// A WriteHeader after the body has started must not change the captured status
+	rw.WriteHeader(http.StatusInternalServerError)
+	if rw.statusCode != http.StatusOK {
+		t.Errorf("statusCode = %d, want %d", rw.statusCode, http.StatusOK)
+	}
+}
+
 func TestMetricsMiddleware(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -175,7 +197,7 @@ func TestMetricsMiddleware(t *testing.T) {
 				}
 			})
 
-			wrapped := MetricsMiddleware(handler)
+			wrapped := MetricsMiddleware(NewMetrics(prometheus.NewRegistry(), MetricsOptions{}))(handler)
 
 			req := httptest.NewRequest(tt.method, tt.path, nil)
 			rec := httptest.NewRecorder()
@@ -200,7 +222,7 @@ func TestMetricsMiddleware_DefaultStatus(t *testing.T) {
 		_, _ = w.Write([]byte("implicit 200"))
 	})
 
-	wrapped := MetricsMiddleware(handler)
+	wrapped := MetricsMiddleware(NewMetrics(prometheus.NewRegistry(), MetricsOptions{}))(handler)
 
 	req := httptest.NewRequest(http.MethodGet, "/implicit", nil)
 	rec := httptest.NewRecorder()
@@ -211,3 +233,122 @@ func TestMetricsMiddleware_DefaultStatus(t *testing.T) {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
 	}
 }
+
+func TestMetricsMiddleware_RecordsToRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg, MetricsOptions{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	wrapped := MetricsMiddleware(m)(handler)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/counted", nil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := testutil.ToFloat64(m.EndpointCount.WithLabelValues("/counted", http.MethodPost, "202")); got != 3 {
+		t.Errorf("http_request_total = %v, want 3", got)
+	}
+
+	if got := testutil.CollectAndCount(m.RequestDuration); got != 1 {
+		t.Errorf("http_request_duration_seconds series = %d, want 1", got)
+	}
+}
+
+func TestNewMetrics_SeparateRegistries(t *testing.T) {
+	// Creating metrics on two registries must not panic with duplicate registration
+	first := NewMetrics(prometheus.NewRegistry(), MetricsOptions{})
+	second := NewMetrics(prometheus.NewRegistry(), MetricsOptions{})
+
+	first.EndpointCount.WithLabelValues("/a", http.MethodGet, "200").Inc()
+
+	if got := testutil.ToFloat64(second.EndpointCount.WithLabelValues("/a", http.MethodGet, "200")); got != 0 {
+		t.Errorf("second registry counter = %v, want 0", got)
+	}
+}
+
+func TestMetricsOptions_HistogramBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []float64
+		want    []float64
+	}{
+		{
+			name:    "empty uses prometheus defaults",
+			buckets: nil,
+			want:    prometheus.DefBuckets,
+		},
+		{
+			name:    "sorted buckets kept as is",
+			buckets: []float64{0.0001, 0.001, 0.01},
+			want:    []float64{0.0001, 0.001, 0.01},
+		},
+		{
+			name:    "unsorted and duplicate buckets normalized",
+			buckets: []float64{0.01, 0.0001, 0.01, 0.001},
+			want:    []float64{0.0001, 0.001, 0.01},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MetricsOptions{Buckets: tt.buckets}.histogramBuckets()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("histogramBuckets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMetrics_NativeHistogram(t *testing.T) {
+	tests := []struct {
+		name       string
+		factor     float64
+		wantNative bool
+		wantSchema int32
+	}{
+		{name: "factor 1.1", factor: 1.1, wantNative: true, wantSchema: 3},
+		{name: "factor 1.5", factor: 1.5, wantNative: true, wantSchema: 1},
+		{name: "factor 2", factor: 2, wantNative: true, wantSchema: 0},
+		{name: "factor 4", factor: 4, wantNative: true, wantSchema: -1},
+		{name: "disabled", factor: 0, wantNative: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := NewMetrics(reg, MetricsOptions{
+				Buckets:                        []float64{0.001, 0.01},
+				NativeHistogramBucketFactor:    tt.factor,
+				NativeHistogramMaxBucketNumber: 100,
+			})
+
+			m.RequestDuration.WithLabelValues("/native", http.MethodGet, "200").Observe(0.0042)
+
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %v", err)
+			}
+
+			for _, mf := range families {
+				if mf.GetName() != "http_request_duration_seconds" {
+					continue
+				}
+				h := mf.GetMetric()[0].GetHistogram()
+				if got := len(h.GetBucket()); got != 2 {
+					t.Errorf("classic bucket count = %d, want 2", got)
+				}
+				if got := len(h.GetPositiveSpan()) > 0; got != tt.wantNative {
+					t.Fatalf("positive spans present = %v, want %v", got, tt.wantNative)
+				}
+				if tt.wantNative && h.GetSchema() != tt.wantSchema {
+					t.Errorf("schema = %d, want %d", h.GetSchema(), tt.wantSchema)
+				}
+				return
+			}
+			t.Fatal("http_request_duration_seconds not gathered")
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RecoveryOptions configures the panic recovery middleware
+type RecoveryOptions struct {
+	// Logger receives the panic value and stack trace
+	Logger *slog.Logger
+	// Panics is incremented for every recovered panic, ignored if nil
+	Panics prometheus.Counter
+	// RequestIDHeader is the response header holding the request ID, X-Request-ID if empty
+	RequestIDHeader string
+}
+
+// RecoveryMiddleware creates a middleware that converts handler panics into JSON 500 responses
+// A panic after the response has started aborts the connection so the client sees a truncated response
+// It is meant to be the outermost layer so that panics in any other middleware are caught too
+func RecoveryMiddleware(opts RecoveryOptions) func(http.Handler) http.Handler {
+	header := opts.RequestIDHeader
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := newResponseWriter(w)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				// net/http uses ErrAbortHandler to abort a response silently
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				if opts.Panics != nil {
+					opts.Panics.Inc()
+				}
+
+				// The request ID middleware runs inside this one, so read the ID it set on the response
+				opts.Logger.ErrorContext(r.Context(), "panic recovered",
+					"panic", fmt.Sprint(rec),
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", w.Header().Get(header),
+					"stack", string(debug.Stack()),
+				)
+
+				// A response that has already started cannot be replaced, so abort it rather than let it look complete
+				if wrapped.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				WriteError(w, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(wrapped, r)
+		})
+	}
+}
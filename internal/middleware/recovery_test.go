@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantPanics float64
+		wantAbort  bool
+	}{
+		{
+			name: "no panic passes through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			},
+			wantStatus: http.StatusAccepted,
+			wantPanics: 0,
+		},
+		{
+			name: "panic becomes JSON 500",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "internal server error",
+			wantPanics: 1,
+		},
+		{
+			name: "panic after response started aborts it",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte("partial"))
+				panic(errors.New("late failure"))
+			},
+			wantStatus: http.StatusOK,
+			wantPanics: 1,
+			wantAbort:  true,
+		},
+		{
+			name: "panic after flush aborts it",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				panic("late failure")
+			},
+			wantStatus: http.StatusOK,
+			wantPanics: 1,
+			wantAbort:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			panics := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_panics_total"})
+
+			handler := RecoveryMiddleware(RecoveryOptions{
+				Logger: slog.New(slog.NewJSONHandler(&logs, nil)),
+				Panics: panics,
+			})(RequestIDMiddleware(RequestIDOptions{})(tt.handler))
+
+			req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+			req.Header.Set("X-Request-ID", "panic-req")
+			rec := httptest.NewRecorder()
+
+			func() {
+				defer func() {
+					if got := recover(); (got == http.ErrAbortHandler) != tt.wantAbort {
+						t.Errorf("recovered %v, want abort %v", got, tt.wantAbort)
+					}
+				}()
+				handler.ServeHTTP(rec, req)
+			}()
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if got := testutil.ToFloat64(panics); got != tt.wantPanics {
+				t.Errorf("panic count = %v, want %v", got, tt.wantPanics)
+			}
+
+			if tt.wantBody != "" {
+				var errResp ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+					t.Fatalf("failed to decode error response: %v", err)
+				}
+				if errResp.Error != tt.wantBody {
+					t.Errorf("error = %q, want %q", errResp.Error, tt.wantBody)
+				}
+			}
+
+			if tt.wantPanics > 0 {
+				out := logs.String()
+				if !strings.Contains(out, `"request_id":"panic-req"`) || !strings.Contains(out, `"stack":`) {
+					t.Errorf("panic log = %q, want request_id and stack", out)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddleware_ErrAbortHandler(t *testing.T) {
+	handler := RecoveryMiddleware(RecoveryOptions{
+		Logger: slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to be re-panicked", rec)
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecoveryMiddleware_Flush(t *testing.T) {
+	handler := RecoveryMiddleware(RecoveryOptions{
+		Logger: slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("ResponseWriter does not implement http.Flusher")
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header used to accept and return request IDs
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// Request ID formats for generated IDs
+const (
+	RequestIDFormatUUIDv7 = "uuidv7"
+	RequestIDFormatULID   = "ulid"
+)
+
+// maxRequestIDLength bounds incoming request IDs so clients cannot bloat logs
+const maxRequestIDLength = 128
+
+// RequestIDOptions configures the request ID middleware
+type RequestIDOptions struct {
+	// Header is the request and response header carrying the ID, X-Request-ID if empty
+	Header string
+	// Format is the format of generated IDs, uuidv7 or ulid
+	Format string
+}
+
+// requestIDKey is the context key for the request ID
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware creates a middleware that accepts a valid incoming request ID or generates one
+// The ID is stored on the request context and returned in the response headers
+func RequestIDMiddleware(opts RequestIDOptions) func(http.Handler) http.Handler {
+	header := opts.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	generate := newUUIDv7
+	if opts.Format == RequestIDFormatULID {
+		generate = newULID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				id = generate(time.Now())
+				r.Header.Set(header, id)
+			}
+
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+// validRequestID reports whether an incoming ID is short and limited to safe characters
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newUUIDv7 generates an RFC 9562 version 7 UUID from the given time
+func newUUIDv7(now time.Time) string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+
+	// 48-bit big-endian unix millisecond timestamp
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now.UnixMilli()))
+	copy(u[:6], ts[2:])
+
+	u[6] = (u[6] & 0x0f) | 0x70 // version 7
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 9562 variant
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID generates a ULID from the given time
+func newULID(now time.Time) string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now.UnixMilli()))
+	copy(u[:6], ts[2:])
+
+	// 128 bits encode to 26 base32 characters, the first holding only the top 3 bits
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// requestIDHandler is a slog.Handler that adds the request ID from the context to each record
+type requestIDHandler struct {
+	slog.Handler
+}
+
+// NewRequestIDLogHandler wraps h so records logged with a request context carry its request_id
+func NewRequestIDLogHandler(h slog.Handler) slog.Handler {
+	return &requestIDHandler{Handler: h}
+}
+
+// Handle adds the request_id attribute unless the record already has one
+func (h *requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" && !hasAttr(r, "request_id") {
+		r = r.Clone()
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the request ID behaviour on derived handlers
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the request ID behaviour on derived handlers
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// hasAttr reports whether the record has a top-level attribute with the given key
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	uuidv7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        RequestIDOptions
+		header      string
+		incoming    string
+		wantID      string
+		wantPattern *regexp.Regexp
+	}{
+		{
+			name:     "valid incoming ID is kept",
+			header:   "X-Request-ID",
+			incoming: "abc-123",
+			wantID:   "abc-123",
+		},
+		{
+			name:        "missing ID generates uuidv7",
+			header:      "X-Request-ID",
+			wantPattern: uuidv7Pattern,
+		},
+		{
+			name:        "invalid incoming ID is replaced",
+			header:      "X-Request-ID",
+			incoming:    "bad id\nwith newline",
+			wantPattern: uuidv7Pattern,
+		},
+		{
+			name:        "overlong incoming ID is replaced",
+			header:      "X-Request-ID",
+			incoming:    strings.Repeat("a", maxRequestIDLength+1),
+			wantPattern: uuidv7Pattern,
+		},
+		{
+			name:        "ulid format",
+			opts:        RequestIDOptions{Format: RequestIDFormatULID},
+			header:      "X-Request-ID",
+			wantPattern: ulidPattern,
+		},
+		{
+			name:     "custom header",
+			opts:     RequestIDOptions{Header: "X-Correlation-ID"},
+			header:   "X-Correlation-ID",
+			incoming: "corr-1",
+			wantID:   "corr-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			handler := RequestIDMiddleware(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(tt.header, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			respID := rec.Header().Get(tt.header)
+			if respID != ctxID {
+				t.Errorf("response ID %q != context ID %q", respID, ctxID)
+			}
+
+			if tt.wantID != "" && ctxID != tt.wantID {
+				t.Errorf("request ID = %q, want %q", ctxID, tt.wantID)
+			}
+
+			if tt.wantPattern != nil && !tt.wantPattern.MatchString(ctxID) {
+				t.Errorf("request ID = %q, want match for %s", ctxID, tt.wantPattern)
+			}
+		})
+	}
+}
+
+func TestNewUUIDv7_Ordering(t *testing.T) {
+	earlier := newUUIDv7(time.UnixMilli(1_700_000_000_000))
+	later := newUUIDv7(time.UnixMilli(1_700_000_000_001))
+
+	if earlier >= later {
+		t.Errorf("uuidv7 %q should sort before %q", earlier, later)
+	}
+	if !strings.HasPrefix(earlier, "018bcfe5-6800-7") {
+		t.Errorf("uuidv7 %q does not encode the timestamp", earlier)
+	}
+}
+
+func TestNewULID_Ordering(t *testing.T) {
+	earlier := newULID(time.UnixMilli(1_700_000_000_000))
+	later := newULID(time.UnixMilli(1_700_000_000_001))
+
+	if earlier >= later {
+		t.Errorf("ulid %q should sort before %q", earlier, later)
+	}
+	if !strings.HasPrefix(earlier, "01HF7YAT00") {
+		t.Errorf("ulid %q does not encode the timestamp", earlier)
+	}
+}
+
+func TestRequestIDLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRequestIDLogHandler(slog.NewJSONHandler(&buf, nil)))
+
+	handler := RequestIDMiddleware(RequestIDOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.With("component", "test").InfoContext(r.Context(), "inside request")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "log-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode log record: %v", err)
+	}
+	if record["request_id"] != "log-123" {
+		t.Errorf("request_id = %v, want log-123", record["request_id"])
+	}
+
+	// Records without a request context are unchanged
+	buf.Reset()
+	logger.Info("outside request")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("unexpected request_id in %q", buf.String())
+	}
+}
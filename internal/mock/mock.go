@@ -0,0 +1,387 @@
+// Package mock serves static responses for routes defined in a YAML, TOML or JSON file, reloading it when it changes
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// File is the layout of a mock routes file
+type File struct {
+	Routes []Route `yaml:"routes" toml:"routes"`
+}
+
+// Route is one mock route and its canned response
+type Route struct {
+	// Method restricts the route to one HTTP method, empty matches any method
+	Method string `yaml:"method" toml:"method"`
+	// Pattern is a ServeMux pattern such as "/users/{id}"
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	// Status is the response status, 200 if unset
+	Status int `yaml:"status" toml:"status"`
+	// Headers are set on the response, {name} is replaced with the path wildcard of that name
+	Headers map[string]string `yaml:"headers" toml:"headers"`
+	// Body is the response body, {name} is replaced with the path wildcard of that name, JSON-escaped when Content-Type is JSON
+	Body string `yaml:"body" toml:"body"`
+	// BodyFile reads the body from a file instead, relative to the routes file
+	BodyFile string `yaml:"body_file" toml:"body_file"`
+	// Delay is how long to wait before responding, such as "250ms"
+	Delay string `yaml:"delay" toml:"delay"`
+}
+
+// ReadFile parses a mock routes file, chosen by its extension
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+		// JSON is a subset of YAML, so one strict decoder covers both
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported mock routes file extension %q, use .yaml, .yml, .toml or .json", path, ext)
+	}
+	return f, nil
+}
+
+// Load reads the routes file at path and returns a mux serving its routes
+func Load(path string) (*http.ServeMux, int, error) {
+	mux, patterns, err := load(path)
+	return mux, len(patterns), err
+}
+
+// load reads the routes file at path and returns a mux serving its routes and their patterns in file order
+func load(path string) (*http.ServeMux, []string, error) {
+	f, err := ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	patterns := make([]string, 0, len(f.Routes))
+	for i, route := range f.Routes {
+		handler, err := route.handler(filepath.Dir(path))
+		if err == nil {
+			err = register(mux, route.pattern(), handler)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: routes[%d] %q: %w", path, i, route.pattern(), err)
+		}
+		patterns = append(patterns, route.pattern())
+	}
+	return mux, patterns, nil
+}
+
+// pattern returns the ServeMux pattern including the method
+func (r Route) pattern() string {
+	return strings.TrimSpace(strings.ToUpper(r.Method) + " " + r.Pattern)
+}
+
+// handler validates the route and returns the handler serving its response
+func (r Route) handler(dir string) (http.Handler, error) {
+	if r.Pattern == "" {
+		return nil, errors.New("pattern is required")
+	}
+	if r.Method != "" && strings.ContainsAny(strings.TrimSpace(r.Pattern), " \t") {
+		return nil, errors.New("set the method in method or pattern, not both")
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 100 || status > 599 {
+		return nil, fmt.Errorf("invalid status %d", status)
+	}
+
+	var delay time.Duration
+	if r.Delay != "" {
+		d, err := time.ParseDuration(r.Delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid delay %q, use a value such as 250ms or 2s", r.Delay)
+		}
+		delay = d
+	}
+
+	body := r.Body
+	if r.BodyFile != "" {
+		if body != "" {
+			return nil, errors.New("set only one of body and body_file")
+		}
+		path := r.BodyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("body_file: %w", err)
+		}
+		body = string(data)
+	}
+
+	return &response{
+		status:    status,
+		headers:   r.Headers,
+		body:      body,
+		delay:     delay,
+		wildcards: wildcards(r.Pattern),
+		jsonBody:  isJSON(r.Headers),
+	}, nil
+}
+
+// register adds handler to mux, reporting invalid or conflicting patterns as errors rather than panics
+func register(mux *http.ServeMux, pattern string, handler http.Handler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%v", v)
+		}
+	}()
+	mux.Handle(pattern, handler)
+	return nil
+}
+
+// isJSON reports whether headers set a JSON Content-Type, such as application/json or application/problem+json
+func isJSON(headers map[string]string) bool {
+	for name, value := range headers {
+		if http.CanonicalHeaderKey(name) != "Content-Type" {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(value)
+		return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+	}
+	return false
+}
+
+// jsonEscape returns value escaped for use inside a JSON string, without the surrounding quotes
+func jsonEscape(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted[1 : len(quoted)-1])
+}
+
+// wildcards returns the names of the path wildcards in pattern, such as "id" for "/users/{id}"
+func wildcards(pattern string) []string {
+	var names []string
+	for rest := pattern; ; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return names
+		}
+		if name := strings.TrimSuffix(rest[start+1:start+end], "..."); name != "$" {
+			names = append(names, name)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// response serves a mock route's canned response
+type response struct {
+	status    int
+	headers   map[string]string
+	body      string
+	delay     time.Duration
+	wildcards []string
+	// jsonBody escapes wildcard values substituted into the body so they stay valid inside JSON strings
+	jsonBody bool
+}
+
+// ServeHTTP waits for the delay, then writes the response with path wildcards substituted
+func (m *response) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.delay > 0 {
+		timer := time.NewTimer(m.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	pairs := make([]string, 0, 2*len(m.wildcards))
+	bodyPairs := make([]string, 0, 2*len(m.wildcards))
+	for _, name := range m.wildcards {
+		value := r.PathValue(name)
+		pairs = append(pairs, "{"+name+"}", value)
+		if m.jsonBody {
+			value = jsonEscape(value)
+		}
+		bodyPairs = append(bodyPairs, "{"+name+"}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	for name, value := range m.headers {
+		w.Header().Set(name, replacer.Replace(value))
+	}
+	w.WriteHeader(m.status)
+	_, _ = strings.NewReplacer(bodyPairs...).WriteString(w, m.body)
+}
+
+// Handler serves the routes from a mock routes file and swaps them in when the file changes
+type Handler struct {
+	path   string
+	logger *slog.Logger
+
+	mux atomic.Pointer[http.ServeMux]
+
+	// modTime and size identify the last version of the file that was read, guarded by mu
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	// patterns are the current route patterns and mounts the patterns registered on each mux passed to Mount, guarded by mu
+	patterns []string
+	mounts   map[*http.ServeMux]map[string]bool
+}
+
+// New loads the routes file at path
+func New(path string, logger *slog.Logger) (*Handler, error) {
+	h := &Handler{path: path, logger: logger}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ServeHTTP serves the request from the current routes, unmatched requests get 404 or 405
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.Load().ServeHTTP(w, r)
+}
+
+// Mount registers every route pattern on mux, dispatching to the current routes so reloads take effect
+// A pattern that conflicts with one already on mux is returned as an error, and patterns added by later reloads are registered too
+func (h *Handler) Mount(mux *http.ServeMux) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.mounts == nil {
+		h.mounts = make(map[*http.ServeMux]map[string]bool)
+	}
+	if h.mounts[mux] == nil {
+		h.mounts[mux] = make(map[string]bool)
+	}
+	return h.mount(mux, h.patterns)
+}
+
+// mount registers the patterns not yet on mux, the caller holds mu
+// Patterns removed by a reload stay registered and get 404 from the current routes, as ServeMux cannot remove them
+func (h *Handler) mount(mux *http.ServeMux, patterns []string) error {
+	for i, pattern := range patterns {
+		if h.mounts[mux][pattern] {
+			continue
+		}
+		if err := register(mux, pattern, h); err != nil {
+			return fmt.Errorf("%s: routes[%d] %q: %w", h.path, i, pattern, err)
+		}
+		h.mounts[mux][pattern] = true
+	}
+	return nil
+}
+
+// Reload reads the routes file again, keeping the current routes if it is invalid or empty
+func (h *Handler) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	// Remember the version even if it is invalid, so Watch reports it once rather than on every tick
+	h.modTime, h.size = info.ModTime(), info.Size()
+
+	// An empty file is most likely one being rewritten in place, so it never replaces loaded routes
+	if info.Size() == 0 && h.mux.Load() != nil {
+		return fmt.Errorf("%s: file is empty", h.path)
+	}
+
+	mux, patterns, err := load(h.path)
+	if err != nil {
+		return err
+	}
+	for mounted := range h.mounts {
+		if err := h.mount(mounted, patterns); err != nil {
+			return err
+		}
+	}
+	h.mux.Store(mux)
+	h.patterns = patterns
+	h.logger.Info("mock routes loaded", "path", h.path, "routes", len(patterns))
+	return nil
+}
+
+// changed returns the file's current version if it differs from the version last read
+func (h *Handler) changed() (os.FileInfo, bool) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return info, !info.ModTime().Equal(h.modTime) || info.Size() != h.size
+}
+
+// Watch reloads the routes file whenever its modification time or size changes, until ctx is done
+// Polling works on every platform and with files replaced by renames, such as Kubernetes ConfigMap updates
+// A change is only reloaded once the file has stayed the same for a whole interval, so a file truncated
+// and rewritten in place is not read while it is empty or half written
+func (h *Handler) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending os.FileInfo
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, ok := h.changed()
+		if !ok {
+			pending = nil
+			continue
+		}
+		if pending == nil || !info.ModTime().Equal(pending.ModTime()) || info.Size() != pending.Size() {
+			pending = info
+			continue
+		}
+		pending = nil
+		if err := h.Reload(); err != nil {
+			h.logger.Error("failed to reload mock routes, keeping the previous routes", "path", h.path, "error", err)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// replaceFile swaps in new content with a rename, so a watcher never reads a partly written file
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := writeFile(t, filepath.Dir(path), filepath.Base(path)+".tmp", content)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+}
+
+// serve sends a request to h and returns the recorded response
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestLoad_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "routes.yaml",
+			content: `
+routes:
+  - method: get
+    pattern: /users/{id}
+    status: 201
+    headers:
+      Content-Type: application/json
+      Location: /users/{id}
+    body: '{"id":"{id}"}'
+`,
+		},
+		{
+			name: "toml",
+			file: "routes.toml",
+			content: `
+[[routes]]
+method = "GET"
+pattern = "/users/{id}"
+status = 201
+body = '{"id":"{id}"}'
+[routes.headers]
+Content-Type = "application/json"
+Location = "/users/{id}"
+`,
+		},
+		{
+			name:    "json",
+			file:    "routes.json",
+			content: `{"routes": [{"method": "GET", "pattern": "/users/{id}", "status": 201, "headers": {"Content-Type": "application/json", "Location": "/users/{id}"}, "body": "{\"id\":\"{id}\"}"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, count, err := Load(writeFile(t, t.TempDir(), tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if count != 1 {
+				t.Errorf("count = %d, want 1", count)
+			}
+
+			rec := serve(mux, http.MethodGet, "/users/42")
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+			}
+			if got := rec.Body.String(); got != `{"id":"42"}` {
+				t.Errorf("body = %q, want %q", got, `{"id":"42"}`)
+			}
+			if got := rec.Header().Get("Location"); got != "/users/42" {
+				t.Errorf("Location = %q, want %q", got, "/users/42")
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			if rec := serve(mux, http.MethodPost, "/users/42"); rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+			}
+		})
+	}
+}
+
+func TestLoad_Routes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "order.json", `{"order":"{id}"}`)
+	path := writeFile(t, dir, "routes.yaml", `
+routes:
+  - pattern: /ping
+    body: pong
+  - method: POST
+    pattern: /orders/{id}
+    status: 202
+    body_file: order.json
+  - pattern: /files/{path...}
+    body: "file {path}"
+  - pattern: /{$}
+    status: 204
+`)
+
+	mux, count, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if count != 4 {
+		t.Errorf("count = %d, want 4", count)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "any method", method: http.MethodDelete, target: "/ping", wantStatus: http.StatusOK, wantBody: "pong"},
+		{name: "body file with wildcard", method: http.MethodPost, target: "/orders/7", wantStatus: http.StatusAccepted, wantBody: `{"order":"7"}`},
+		{name: "remainder wildcard", method: http.MethodGet, target: "/files/a/b.txt", wantStatus: http.StatusOK, wantBody: "file a/b.txt"},
+		{name: "exact root", method: http.MethodGet, target: "/", wantStatus: http.StatusNoContent},
+		{name: "unmatched", method: http.MethodGet, target: "/missing", wantStatus: http.StatusNotFound, wantBody: "404 page not found\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux, tt.method, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantMessage string
+	}{
+		{name: "unknown extension", file: "routes.ini", content: "", wantMessage: `unsupported mock routes file extension ".ini"`},
+		{name: "unknown yaml key", file: "routes.yaml", content: "routes:\n  - pattern: /a\n    stauts: 200\n", wantMessage: "field stauts not found"},
+		{name: "unknown toml key", file: "routes.toml", content: "[[routes]]\npattern = \"/a\"\nstauts = 200\n", wantMessage: `unknown key "routes.stauts"`},
+		{name: "missing pattern", file: "routes.yaml", content: "routes:\n  - body: hi\n", wantMessage: "routes[0] \"\": pattern is required"},
+		{name: "method twice", file: "routes.yaml", content: "routes:\n  - method: GET\n    pattern: GET /a\n", wantMessage: "set the method in method or pattern, not both"},
+		{name: "invalid status", file: "routes.yaml", content: "routes:\n  - pattern: /a\n    status: 700\n", wantMessage: "invalid status 700"},
+		{name: "invalid delay", file: "routes.yaml", content: "routes:\n  - pattern: /a\n    delay: soon\n", wantMessage: `invalid delay "soon"`},
+		{name: "body and body file", file: "routes.yaml", content: "routes:\n  - pattern: /a\n    body: hi\n    body_file: a.json\n", wantMessage: "set only one of body and body_file"},
+		{name: "missing body file", file: "routes.yaml", content: "routes:\n  - pattern: /a\n    body_file: missing.json\n", wantMessage: "body_file: open "},
+		{name: "invalid pattern", file: "routes.yaml", content: "routes:\n  - pattern: /a/{\n", wantMessage: `routes[0] "/a/{"`},
+		{name: "duplicate pattern", file: "routes.yaml", content: "routes:\n  - pattern: /a\n  - pattern: /a\n", wantMessage: `routes[1] "/a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load(writeFile(t, t.TempDir(), tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestResponse_WildcardEscaping(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "routes.yaml", `
+routes:
+  - pattern: /users/{id}
+    headers:
+      content-type: application/json; charset=utf-8
+    body: '{"id":"{id}"}'
+  - pattern: /problems/{id}
+    headers:
+      Content-Type: application/problem+json
+    body: '{"detail":"{id}"}'
+  - pattern: /text/{id}
+    body: 'text {id}'
+`)
+	mux, _, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantBody string
+		wantJSON bool
+	}{
+		{name: "quote in JSON", target: "/users/a%22b", wantBody: `{"id":"a\"b"}`, wantJSON: true},
+		{name: "backslash in JSON", target: "/users/a%5Cb", wantBody: `{"id":"a\\b"}`, wantJSON: true},
+		{name: "JSON suffix media type", target: "/problems/a%22b", wantBody: `{"detail":"a\"b"}`, wantJSON: true},
+		{name: "other content types are inserted as is", target: "/text/a%22b", wantBody: `text a"b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := serve(mux, http.MethodGet, tt.target).Body.Bytes()
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			if tt.wantJSON && !json.Valid(body) {
+				t.Errorf("body %s is not valid JSON", body)
+			}
+		})
+	}
+}
+
+func TestResponse_Delay(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "routes.yaml", "routes:\n  - pattern: /slow\n    delay: 50ms\n    body: done\n")
+	mux, _, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	start := time.Now()
+	if rec := serve(mux, http.MethodGet, "/slow"); rec.Body.String() != "done" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "done")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("responded after %v, want at least 50ms", elapsed)
+	}
+
+	// A cancelled request stops waiting without writing a response
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q for a cancelled request, want nothing", rec.Body.String())
+	}
+}
+
+func TestHandler_Watch(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "routes.yaml", "routes:\n  - pattern: /a\n    body: first\n")
+	h, err := New(path, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Watch(ctx, 10*time.Millisecond)
+
+	waitFor := func(target, want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got := serve(h, http.MethodGet, target).Body.String()
+			if got == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("GET %s body = %q, want %q", target, got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("/a", "first")
+
+	// Edits to the file are picked up without a restart
+	writeFile(t, filepath.Dir(path), "routes.yaml", "routes:\n  - pattern: /a\n    body: second\n  - pattern: /b\n    body: new\n")
+	waitFor("/a", "second")
+	waitFor("/b", "new")
+
+	// An invalid file keeps the previous routes
+	writeFile(t, filepath.Dir(path), "routes.yaml", "routes:\n  - pattern: /a\n    status: 1000\n")
+	time.Sleep(50 * time.Millisecond)
+	waitFor("/a", "second")
+
+	// So does an empty one, as left behind by a truncating write
+	writeFile(t, filepath.Dir(path), "routes.yaml", "")
+	time.Sleep(50 * time.Millisecond)
+	waitFor("/a", "second")
+}
+
+func TestHandler_Mount(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "routes.yaml", "routes:\n  - method: GET\n    pattern: /users/{id}\n    body: user {id}\n")
+	h, err := New(path, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "ok") })
+	if err := h.Mount(mux); err != nil {
+		t.Fatalf("Mount() error = %v", err)
+	}
+
+	if got := serve(mux, http.MethodGet, "/users/7").Body.String(); got != "user 7" {
+		t.Errorf("GET /users/7 body = %q, want %q", got, "user 7")
+	}
+	if got := serve(mux, http.MethodPost, "/users/7").Code; got != http.StatusMethodNotAllowed {
+		t.Errorf("POST /users/7 status = %d, want %d", got, http.StatusMethodNotAllowed)
+	}
+
+	// Routes added by a reload are registered on the mounted mux
+	replaceFile(t, path, "routes:\n  - method: GET\n    pattern: /users/{id}\n    body: user {id}\n  - pattern: /orders\n    body: orders\n")
+	if err := h.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := serve(mux, http.MethodGet, "/orders").Body.String(); got != "orders" {
+		t.Errorf("GET /orders body = %q, want %q", got, "orders")
+	}
+
+	// A reload that conflicts with a route on the mux fails and keeps the previous routes
+	replaceFile(t, path, "routes:\n  - method: GET\n    pattern: /health\n    body: shadowed\n")
+	if err := h.Reload(); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("Reload() error = %v, want a conflict with GET /health", err)
+	}
+	if got := serve(mux, http.MethodGet, "/health").Body.String(); got != "ok" {
+		t.Errorf("GET /health body = %q, want %q", got, "ok")
+	}
+	if got := serve(mux, http.MethodGet, "/orders").Body.String(); got != "orders" {
+		t.Errorf("GET /orders body = %q after a failed reload, want %q", got, "orders")
+	}
+}
+
+func TestNew_InvalidFile(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.yaml"), slog.Default()); err == nil {
+		t.Error("New() with a missing file error = nil, want an error")
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cUpgrader switches "Upgrade: h2c" requests (RFC 7540 section 3.2) to HTTP/2 on the same connection
+// Prior-knowledge h2c is handled by net/http itself through http.Server.Protocols
+type h2cUpgrader struct {
+	handler http.Handler
+	h2      *http2.Server
+
+	// conns tracks upgraded connections, which http.Server no longer knows about after the hijack
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// newH2CUpgrader wraps handler so bodiless h2c upgrade requests are served over HTTP/2 by h2
+func newH2CUpgrader(handler http.Handler, h2 *http2.Server) *h2cUpgrader {
+	return &h2cUpgrader{handler: handler, h2: h2, conns: make(map[net.Conn]struct{})}
+}
+
+// ServeHTTP upgrades eligible requests and serves everything else unchanged
+// Requests with a body keep HTTP/1.1, which RFC 7540 permits by ignoring the Upgrade header
+func (u *h2cUpgrader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	settings, ok := h2cUpgradeSettings(r)
+	if !ok {
+		u.handler.ServeHTTP(w, r)
+		return
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		u.handler.ServeHTTP(w, r)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n"); err != nil {
+		return
+	}
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	u.track(conn, true)
+	defer u.track(conn, false)
+
+	// The upgrade request is answered as stream 1 of the new HTTP/2 connection
+	r.Header.Del("Upgrade")
+	r.Header.Del("Connection")
+	r.Header.Del("HTTP2-Settings")
+	base, _ := r.Context().Value(http.ServerContextKey).(*http.Server)
+	u.h2.ServeConn(&bufferedConn{Conn: conn, reader: rw.Reader}, &http2.ServeConnOpts{
+		Context:        r.Context(),
+		BaseConfig:     base,
+		Handler:        u.handler,
+		UpgradeRequest: r,
+		Settings:       settings,
+	})
+}
+
+// track adds or removes an upgraded connection
+func (u *h2cUpgrader) track(conn net.Conn, add bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if add {
+		u.conns[conn] = struct{}{}
+	} else {
+		delete(u.conns, conn)
+	}
+}
+
+// closeAll closes every upgraded connection, called when the http.Server shuts down
+func (u *h2cUpgrader) closeAll() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for conn := range u.conns {
+		_ = conn.Close()
+	}
+}
+
+// h2cUpgradeSettings returns the decoded HTTP2-Settings of a bodiless h2c upgrade request
+func h2cUpgradeSettings(r *http.Request) ([]byte, bool) {
+	if r.ProtoMajor != 1 || r.ContentLength != 0 || !headerHasToken(r.Header, "Upgrade", "h2c") ||
+		!headerHasToken(r.Header, "Connection", "Upgrade") || !headerHasToken(r.Header, "Connection", "HTTP2-Settings") {
+		return nil, false
+	}
+
+	values := r.Header.Values("HTTP2-Settings")
+	if len(values) != 1 {
+		return nil, false
+	}
+	settings, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil {
+		return nil, false
+	}
+	return settings, true
+}
+
+// headerHasToken reports whether any comma-separated value of header equals token, ignoring case
+func headerHasToken(h http.Header, header, token string) bool {
+	for _, value := range h.Values(header) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bufferedConn reads bytes the HTTP/1 server already buffered before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read drains the buffered reader, which falls through to the connection once empty
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/handlers"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// startH2CServer starts a server with h2c enabled and stops it when the test ends
+func startH2CServer(t *testing.T, enabled bool) *Server {
+	t.Helper()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{H2CEnabled: enabled, HTTP2MaxConcurrentStreams: 10}
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+	t.Cleanup(func() {
+		cancel()
+		_ = waitStart(t, done)
+	})
+	return s
+}
+
+func TestH2C_PriorKnowledge(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		wantProto string
+	}{
+		{name: "enabled", enabled: true, wantProto: "HTTP/2.0"},
+		{name: "disabled", enabled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startH2CServer(t, tt.enabled)
+
+			transport := &http.Transport{Protocols: new(http.Protocols)}
+			transport.Protocols.SetUnencryptedHTTP2(true)
+			defer transport.CloseIdleConnections()
+			client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+			resp, err := client.Post("http://"+s.Addr().String()+"/api/v1/echo", "application/json", strings.NewReader(`{"h2c":true}`))
+			if tt.wantProto == "" {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Error("prior-knowledge request succeeded with h2c disabled")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("POST over h2c: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.Proto != tt.wantProto {
+				t.Errorf("response proto = %q, want %q", resp.Proto, tt.wantProto)
+			}
+			if got := resp.Header.Get(handlers.ProtocolHeader); got != tt.wantProto {
+				t.Errorf("%s = %q, want %q", handlers.ProtocolHeader, got, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestH2C_Upgrade(t *testing.T) {
+	s := startH2CServer(t, true)
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// An empty SETTINGS payload encodes to an empty HTTP2-Settings value
+	_, _ = io.WriteString(conn, "GET /health HTTP/1.1\r\nHost: echo\r\n"+
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("reading upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	// The client must still send its preface and SETTINGS after the upgrade
+	_, _ = io.WriteString(conn, http2.ClientPreface)
+	framer := http2.NewFramer(conn, br)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatalf("WriteSettings: %v", err)
+	}
+
+	// The upgrade request is answered on stream 1
+	decoder := hpack.NewDecoder(4096, nil)
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		headers, ok := frame.(*http2.HeadersFrame)
+		if !ok || headers.StreamID != 1 {
+			continue
+		}
+		fields, err := decoder.DecodeFull(headers.HeaderBlockFragment())
+		if err != nil {
+			t.Fatalf("decoding headers: %v", err)
+		}
+		for _, f := range fields {
+			if f.Name == ":status" && f.Value != "200" {
+				t.Errorf(":status = %s, want 200", f.Value)
+			}
+		}
+		return
+	}
+}
+
+func TestH2C_HTTP2Config(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{
+		H2CEnabled:                true,
+		HTTP2MaxConcurrentStreams: 42,
+		HTTP2MaxReadFrameSize:     1 << 15,
+		Listeners: []config.ListenerConfig{
+			{Name: "plain", Addr: ":0"},
+			{Name: "tls", Addr: ":0", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+		},
+	}
+	s := NewServer(logger, http.NewServeMux(), ":0", cfg)
+
+	for _, e := range s.entries {
+		if e.server.HTTP2 == nil || e.server.HTTP2.MaxConcurrentStreams != 42 || e.server.HTTP2.MaxReadFrameSize != 1<<15 {
+			t.Errorf("listener %q HTTP2 = %+v, want configured limits", e.name, e.server.HTTP2)
+		}
+
+		wantH2C := e.name == MainListener || e.name == "plain"
+		gotH2C := e.server.Protocols != nil && e.server.Protocols.UnencryptedHTTP2()
+		if gotH2C != wantH2C {
+			t.Errorf("listener %q h2c = %v, want %v", e.name, gotH2C, wantH2C)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// errHTTP3RequiresTLS is returned by Start when HTTP/3 is enabled on a main listener without a certificate
+var errHTTP3RequiresTLS = errors.New("http3: TLS_CERT_FILE and TLS_KEY_FILE are required for HTTP/3")
+
+// quicMetrics tracks QUIC connections accepted by the HTTP/3 listener
+type quicMetrics struct {
+	connections prometheus.Counter
+	active      prometheus.Gauge
+	duration    prometheus.Histogram
+}
+
+// newQUICMetrics creates and registers the QUIC connection metrics on reg
+func newQUICMetrics(reg prometheus.Registerer) *quicMetrics {
+	m := &quicMetrics{
+		connections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "quic_connections_total",
+			Help: "Total number of QUIC connections accepted by the HTTP/3 listener.",
+		}),
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "quic_connections_active",
+			Help: "Number of open QUIC connections on the HTTP/3 listener.",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "quic_connection_duration_seconds",
+			Help:    "Lifetime of closed QUIC connections in seconds.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}),
+	}
+	reg.MustRegister(m.connections, m.active, m.duration)
+	return m
+}
+
+// connContext records a new QUIC connection and observes its lifetime once it closes
+func (m *quicMetrics) connContext(ctx context.Context, c *quic.Conn) context.Context {
+	m.connections.Inc()
+	m.active.Inc()
+	start := time.Now()
+	context.AfterFunc(c.Context(), func() {
+		m.active.Dec()
+		m.duration.Observe(time.Since(start).Seconds())
+	})
+	return ctx
+}
+
+// newHTTP3Server creates an HTTP/3 server sharing handler and the limits of the main server
+// TLS is configured in Start once the main listener's certificate is loaded
+// 0-RTT stays off because early data can be replayed against non-idempotent routes such as POST /api/v1/echo
+func (s *Server) newHTTP3Server(handler http.Handler) *http3.Server {
+	s.quic = newQUICMetrics(s.registry)
+	return &http3.Server{
+		Handler:        handler,
+		MaxHeaderBytes: s.server.MaxHeaderBytes,
+		IdleTimeout:    s.server.IdleTimeout,
+		QUICConfig:     &quic.Config{Allow0RTT: false},
+		ConnContext:    s.quic.connContext,
+		Logger:         s.logger,
+	}
+}
+
+// http3Address returns the UDP address of the HTTP/3 listener, the main TCP address unless overridden
+func (s *Server) http3Address() (string, error) {
+	if s.config.HTTP3Addr != "" {
+		return s.config.HTTP3Addr, nil
+	}
+	if strings.Contains(s.port, "unix:") || strings.HasPrefix(s.port, "systemd") {
+		return "", fmt.Errorf("http3: main listener %q has no UDP equivalent, set HTTP3_ADDR", s.port)
+	}
+	return s.port, nil
+}
+
+// listenHTTP3 binds the UDP socket for HTTP/3 using the main listener's certificate
+// http3.Server never closes a socket passed to Serve, so shutdown closes it once the server has stopped
+func (s *Server) listenHTTP3(mainTLS *tls.Config) (net.PacketConn, error) {
+	if mainTLS == nil {
+		return nil, errHTTP3RequiresTLS
+	}
+	addr, err := s.http3Address()
+	if err != nil {
+		return nil, err
+	}
+
+	s.http3.TLSConfig = http3.ConfigureTLSConfig(mainTLS)
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("http3: listen on %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// altSvc advertises the HTTP/3 listener to clients of the TCP listener
+func (s *Server) altSvc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The header is only known once the UDP socket is bound, so errors before then are ignored
+		_ = s.http3.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HTTP3Addr returns the bound UDP address of the HTTP/3 listener, or nil if there is none
+func (s *Server) HTTP3Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.http3Conn == nil {
+		return nil
+	}
+	return s.http3Conn.LocalAddr()
+}
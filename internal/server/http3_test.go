@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/lkendrickd/echo-server/internal/handlers"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3_Loopback(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, HTTP3Enabled: true}
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+
+	udpAddr := s.HTTP3Addr()
+	if udpAddr == nil || udpAddr.(*net.UDPAddr).Port == 0 {
+		t.Fatalf("HTTP3Addr() = %v, want a bound port", udpAddr)
+	}
+
+	// The TLS listener advertises the UDP port through Alt-Svc
+	tcpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := tcpClient.Get("https://" + s.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health over TCP: %v", err)
+	}
+	_ = resp.Body.Close()
+	wantAltSvc := fmt.Sprintf(`h3=":%d"`, udpAddr.(*net.UDPAddr).Port)
+	if got := resp.Header.Get("Alt-Svc"); !strings.HasPrefix(got, wantAltSvc) {
+		t.Errorf("Alt-Svc = %q, want prefix %q", got, wantAltSvc)
+	}
+	tcpClient.CloseIdleConnections()
+
+	transport := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	h3Client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	h3URL := "https://" + udpAddr.String()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "health", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK},
+		{name: "echo", method: http.MethodPost, path: "/api/v1/echo", body: `{"h3":true}`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, h3URL+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := h3Client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s over HTTP/3: %v", tt.method, tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.Proto != "HTTP/3.0" {
+				t.Errorf("response proto = %q, want %q", resp.Proto, "HTTP/3.0")
+			}
+			if tt.method == http.MethodPost {
+				if got := resp.Header.Get(handlers.ProtocolHeader); got != "HTTP/3.0" {
+					t.Errorf("%s = %q, want %q", handlers.ProtocolHeader, got, "HTTP/3.0")
+				}
+			}
+			if got := resp.Header.Get("Alt-Svc"); got != "" {
+				t.Errorf("HTTP/3 response Alt-Svc = %q, want none", got)
+			}
+		})
+	}
+
+	// Both requests share one QUIC connection
+	if got := testutil.ToFloat64(s.quic.connections); got != 1 {
+		t.Errorf("quic_connections_total = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(s.quic.active); got != 1 {
+		t.Errorf("quic_connections_active = %v, want 1", got)
+	}
+
+	_ = transport.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(s.quic.active) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(s.quic.active); got != 0 {
+		t.Errorf("quic_connections_active = %v after close, want 0", got)
+	}
+	if got := testutil.CollectAndCount(s.quic.duration); got != 1 {
+		t.Errorf("quic_connection_duration_seconds series = %d, want 1", got)
+	}
+
+	cancel()
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+
+	// The UDP port is released once the server has shut down
+	conn, err := net.ListenPacket("udp", udpAddr.String())
+	if err != nil {
+		t.Fatalf("UDP port still bound after shutdown: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestHTTP3_Disables0RTT(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", &config.Config{HTTP3Enabled: true})
+
+	if s.http3.QUICConfig == nil || s.http3.QUICConfig.Allow0RTT {
+		t.Errorf("QUICConfig = %+v, want 0-RTT disabled", s.http3.QUICConfig)
+	}
+}
+
+func TestHTTP3_StartErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		cfg     *config.Config
+		wantErr error
+	}{
+		{
+			name:    "requires TLS",
+			addr:    "127.0.0.1:0",
+			cfg:     &config.Config{HTTP3Enabled: true},
+			wantErr: errHTTP3RequiresTLS,
+		},
+		{
+			name: "unix main listener without HTTP3_ADDR",
+			addr: "unix:" + t.TempDir() + "/echo.sock",
+			cfg:  &config.Config{HTTP3Enabled: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if tt.wantErr == nil {
+				cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCert(t)
+			}
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			s := NewServer(logger, http.NewServeMux(), tt.addr, cfg)
+
+			done := make(chan error, 1)
+			go func() { done <- s.Start(context.Background()) }()
+
+			err := waitStart(t, done)
+			if err == nil {
+				t.Fatal("Start() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Start() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,122 +2,853 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/lkendrickd/echo-server/internal/config"
 	"github.com/lkendrickd/echo-server/internal/handlers"
+	"github.com/lkendrickd/echo-server/internal/health"
+	"github.com/lkendrickd/echo-server/internal/listener"
 	"github.com/lkendrickd/echo-server/internal/middleware"
+	"github.com/lkendrickd/echo-server/internal/mock"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
 )
 
 // protectedPrefixes defines the URL prefixes that require authentication
 var protectedPrefixes = []string{"/api/"}
 
+// Names of the built-in listeners
+const (
+	MainListener  = "main"
+	AdminListener = "admin"
+)
+
+// Route groups that can be mounted on a listener
+const (
+	RoutesAPI     = "api"
+	RoutesHealth  = "health"
+	RoutesMetrics = "metrics"
+	RoutesAdmin   = "admin"
+	RoutesMock    = "mock"
+)
+
+// Optional middleware that can be selected per listener
+const (
+	MiddlewareMetrics     = "metrics"
+	MiddlewareCompression = "compression"
+	MiddlewareBodyLimit   = "bodylimit"
+	MiddlewareAuth        = "auth"
+	MiddlewareCORS        = "cors"
+	MiddlewareAccessLog   = "accesslog"
+)
+
+// listenerEntry is one listener with its own mux, route groups and middleware chain
+type listenerEntry struct {
+	name   string
+	mux    *http.ServeMux
+	server *http.Server
+	routes []string
+
+	// certFile and keyFile serve TLS when both are set
+	certFile string
+	keyFile  string
+	// proxyProtocol requires a PROXY protocol header on every connection
+	proxyProtocol bool
+
+	// ln is the bound listener, guarded by Server.mu
+	ln net.Listener
+}
+
 // Server is the HTTP server
 type Server struct {
-	logger *slog.Logger
-	muxer  *http.ServeMux
-	server *http.Server
-	port   string
-	config *config.Config
+	logger   *slog.Logger
+	muxer    *http.ServeMux
+	server   *http.Server
+	port     string
+	config   *config.Config
+	registry *prometheus.Registry
+	metrics  *middleware.Metrics
+	health   *health.Checker
+
+	// logLevel is the level changed through the admin endpoints, nil leaves them unmounted
+	logLevel *slog.LevelVar
+	// mocks serves the routes from MockRoutesFile once loadMocks has read it
+	mocks *mock.Handler
+
+	// accessLog holds the shared access log options, nil when access logging is off
+	accessLog *middleware.AccessLogOptions
+	// accessLogOutput receives common and combined access logs, stdout until Start opens ACCESS_LOG_FILE
+	accessLogOutput *switchWriter
+	// accessLogFile is the file opened for ACCESS_LOG_FILE, if any, guarded by mu
+	accessLogFile *os.File
+
+	// recovery is the panic recovery middleware applied to every listener
+	recovery func(http.Handler) http.Handler
+
+	// adminMuxer and adminServer serve operational endpoints when an admin port is configured
+	adminMuxer  *http.ServeMux
+	adminServer *http.Server
+
+	// entries holds every listener, the main one first, bound listeners are guarded by mu
+	entries []*listenerEntry
+	mu      sync.Mutex
+	ready   chan struct{}
+
+	// http3 serves HTTP/3 over QUIC when enabled, http3Conn is its UDP socket guarded by mu
+	http3     *http3.Server
+	http3Conn net.PacketConn
+	quic      *quicMetrics
+
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
+	shutdownErr  error
 }
 
-// registerMetric safely registers a prometheus collector, ignoring AlreadyRegisteredError
-func registerMetric(c prometheus.Collector) {
-	if err := prometheus.DefaultRegisterer.Register(c); err != nil {
-		var alreadyRegistered prometheus.AlreadyRegisteredError
-		if !errors.As(err, &alreadyRegistered) {
-			panic(err)
-		}
+// newRegistry creates a prometheus registry with the Go runtime and process collectors
+func newRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// metricsOptions maps the metrics settings in cfg to middleware options
+func metricsOptions(cfg *config.Config) middleware.MetricsOptions {
+	if cfg == nil {
+		return middleware.MetricsOptions{}
 	}
+
+	opts := middleware.MetricsOptions{Buckets: cfg.MetricsBuckets}
+	if cfg.MetricsNativeHistograms {
+		opts.NativeHistogramBucketFactor = cfg.MetricsNativeBucketFactor
+		opts.NativeHistogramMaxBucketNumber = cfg.MetricsNativeMaxBuckets
+	}
+	return opts
 }
 
 // NewServer creates a new Server with middleware applied
+// port is a TCP address such as ":8080", "unix:/path/to.sock" or "systemd[:name]" for socket activation
 func NewServer(l *slog.Logger, mux *http.ServeMux, port string, cfg *config.Config) *Server {
-	// Register prometheus metrics, tolerating duplicate registrations
-	registerMetric(middleware.RequestDuration)
-	registerMetric(middleware.EndpointCount)
+	// Each server owns its registry so multiple servers never share metric state
+	registry := newRegistry()
+	metrics := middleware.NewMetrics(registry, metricsOptions(cfg))
+
+	s := &Server{
+		logger:   l,
+		muxer:    mux,
+		port:     port,
+		config:   cfg,
+		registry: registry,
+		metrics:  metrics,
+		health:   health.New(),
+
+		ready:        make(chan struct{}),
+		shutdownDone: make(chan struct{}),
+	}
+
+	// Panic recovery is outermost so panics in any layer become JSON 500 responses
+	s.recovery = middleware.RecoveryMiddleware(middleware.RecoveryOptions{
+		Logger:          l,
+		Panics:          metrics.PanicCount,
+		RequestIDHeader: s.requestIDOptions().Header,
+	})
+
+	// Every listener shares one access log output so the file is only opened once
+	if cfg != nil && cfg.AccessLogEnabled {
+		opts := s.accessLogOptions()
+		s.accessLog = &opts
+	}
+
+	mainHandler := s.chain(mux, nil)
+	s.server = s.newHTTPServer(port, mainHandler)
+
+	// HTTP/3 shares the main chain, and the TCP listener advertises it through Alt-Svc
+	if cfg != nil && cfg.HTTP3Enabled {
+		s.http3 = s.newHTTP3Server(mainHandler)
+		s.server.Handler = s.altSvc(mainHandler)
+	}
+
+	mainTLS := cfg != nil && cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+	if cfg != nil && cfg.H2CEnabled && !mainTLS {
+		s.enableH2C(s.server)
+	}
+	mainRoutes := []string{RoutesAPI, RoutesHealth}
+	if cfg != nil && cfg.MockRoutesFile != "" {
+		mainRoutes = append(mainRoutes, RoutesMock)
+	}
+
+	// Operational endpoints get their own listener so they can stay off the public port
+	// It keeps request IDs and the access log but skips the metrics, body limit and API auth layers
+	if cfg != nil && cfg.AdminPort != "" {
+		s.adminMuxer = http.NewServeMux()
+		s.adminServer = s.newHTTPServer(fmt.Sprintf(":%s", cfg.AdminPort), s.chain(s.adminMuxer, []string{MiddlewareAccessLog}))
+	} else {
+		mainRoutes = append(mainRoutes, RoutesMetrics, RoutesAdmin)
+	}
+
+	s.entries = []*listenerEntry{{
+		name:          MainListener,
+		mux:           mux,
+		server:        s.server,
+		routes:        mainRoutes,
+		proxyProtocol: cfg != nil && cfg.ProxyProtocol,
+	}}
+	if mainTLS {
+		s.entries[0].certFile = cfg.TLSCertFile
+		s.entries[0].keyFile = cfg.TLSKeyFile
+	}
+	if s.adminServer != nil {
+		s.entries = append(s.entries, &listenerEntry{
+			name:   AdminListener,
+			mux:    s.adminMuxer,
+			server: s.adminServer,
+			routes: []string{RoutesHealth, RoutesMetrics, RoutesAdmin},
+		})
+	}
+
+	// Additional listeners each get their own mux, route groups and middleware chain
+	if cfg != nil {
+		for _, lc := range cfg.Listeners {
+			entryMux := http.NewServeMux()
+			routes := lc.Routes
+			if len(routes) == 0 {
+				routes = []string{RoutesAPI, RoutesHealth}
+			}
+			srv := s.newHTTPServer(lc.Addr, s.chain(entryMux, lc.Middleware))
+			if cfg.H2CEnabled && lc.TLSCertFile == "" {
+				s.enableH2C(srv)
+			}
+			s.entries = append(s.entries, &listenerEntry{
+				name:          lc.Name,
+				mux:           entryMux,
+				server:        srv,
+				routes:        routes,
+				certFile:      lc.TLSCertFile,
+				keyFile:       lc.TLSKeyFile,
+				proxyProtocol: lc.ProxyProtocol,
+			})
+		}
+	}
+
+	return s
+}
+
+// chain wraps mux in the standard middleware stack
+// only lists the optional middleware to apply, nil applies all of them subject to the config
+// Request IDs and panic recovery are always applied
+func (s *Server) chain(mux *http.ServeMux, only []string) http.Handler {
+	cfg := s.config
+	enabled := func(name string) bool {
+		return only == nil || slices.Contains(only, name)
+	}
 
 	// Start with metrics middleware
 	var handler http.Handler = mux
-	handler = middleware.MetricsMiddleware(handler)
+	if enabled(MiddlewareMetrics) {
+		handler = middleware.MetricsMiddleware(s.metrics)(handler)
+	}
+
+	// Compression wraps metrics so status codes are captured before the compressing writer
+	if cfg != nil && cfg.CompressionEnabled && enabled(MiddlewareCompression) {
+		handler = middleware.CompressionMiddleware(middleware.CompressionOptions{
+			MinSize:      cfg.CompressionMinSize,
+			ContentTypes: cfg.CompressionContentTypes,
+			Encodings:    cfg.CompressionEncodings,
+		})(handler)
+	}
+
+	// Body limits run after auth so unauthenticated clients are rejected before size checks
+	if cfg != nil && enabled(MiddlewareBodyLimit) {
+		handler = middleware.BodyLimitMiddleware(s.bodyLimitOptions(mux))(handler)
+	}
 
 	// Apply auth middleware if enabled
-	if cfg != nil && cfg.AuthEnabled {
+	if cfg != nil && cfg.AuthEnabled && enabled(MiddlewareAuth) {
 		handler = middleware.AuthMiddleware(cfg, protectedPrefixes)(handler)
 	}
 
-	// Create a new http.Server using the wrapped handler
-	server := &http.Server{
-		Addr:         port,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// CORS runs before auth so browser preflights are answered without credentials
+	if cfg != nil && len(cfg.CORSAllowedOrigins) > 0 && enabled(MiddlewareCORS) {
+		handler = middleware.CORSMiddleware(s.corsOptions())(handler)
+	}
+
+	// Access logging wraps auth so rejected requests and identities are recorded
+	if s.accessLog != nil && enabled(MiddlewareAccessLog) {
+		handler = middleware.AccessLogMiddleware(*s.accessLog)(handler)
+	}
+
+	// The client IP is resolved before access logging so every layer sees the same address
+	handler = middleware.ClientIPMiddleware(s.clientIPOptions())(handler)
+
+	// Request IDs are assigned first so every inner layer can log them
+	handler = middleware.RequestIDMiddleware(s.requestIDOptions())(handler)
+
+	return s.recovery(handler)
+}
+
+// bodyLimitOptions maps the body limit settings in the config to middleware options for mux
+func (s *Server) bodyLimitOptions(mux *http.ServeMux) middleware.BodyLimitOptions {
+	return middleware.BodyLimitOptions{
+		MaxBytes:          s.config.MaxBodyBytes,
+		RouteMaxBytes:     s.config.RouteMaxBodyBytes,
+		RouteContentTypes: s.config.RouteContentTypes,
+		Pattern: func(r *http.Request) string {
+			_, pattern := mux.Handler(r)
+			return pattern
+		},
+		Rejections: s.metrics.Rejections,
+	}
+}
+
+// corsOptions maps the CORS settings in the config to middleware options
+func (s *Server) corsOptions() middleware.CORSOptions {
+	return middleware.CORSOptions{
+		AllowedOrigins:   s.config.CORSAllowedOrigins,
+		AllowedMethods:   s.config.CORSAllowedMethods,
+		AllowedHeaders:   s.config.CORSAllowedHeaders,
+		ExposedHeaders:   s.config.CORSExposedHeaders,
+		AllowCredentials: s.config.CORSAllowCredentials,
+		MaxAge:           s.config.CORSMaxAge,
 	}
+}
 
-	return &Server{
-		logger: l,
-		muxer:  mux,
-		server: server,
-		port:   port,
-		config: cfg,
+// requestIDOptions maps the request ID settings in the config to middleware options
+func (s *Server) requestIDOptions() middleware.RequestIDOptions {
+	if s.config == nil {
+		return middleware.RequestIDOptions{}
+	}
+	return middleware.RequestIDOptions{
+		Header: s.config.RequestIDHeader,
+		Format: s.config.RequestIDFormat,
 	}
 }
 
-// Start starts the server and gracefully handles shutdown
-func (s *Server) Start() error {
-	// Setting up signal capturing
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
+// accessLogOptions builds the access log options, common and combined lines go to stdout until Start opens the file
+func (s *Server) accessLogOptions() middleware.AccessLogOptions {
+	s.accessLogOutput = &switchWriter{Writer: os.Stdout}
+	return middleware.AccessLogOptions{
+		Logger:     s.logger,
+		SampleRate: s.config.AccessLogSampleRate,
+		Fields:     s.config.AccessLogFields,
+		Format:     s.config.AccessLogFormat,
+		Output:     s.accessLogOutput,
+	}
+}
+
+// openAccessLog opens ACCESS_LOG_FILE for common and combined access logs
+func (s *Server) openAccessLog() error {
+	if s.accessLog == nil || s.config.AccessLogFile == "" || s.config.AccessLogFormat == middleware.AccessLogFormatJSON {
+		return nil
+	}
+	f, err := os.OpenFile(s.config.AccessLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("access log: %w", err)
+	}
+	s.mu.Lock()
+	s.accessLogFile = f
+	s.mu.Unlock()
+	s.accessLogOutput.Writer = f
+	return nil
+}
+
+// closeAccessLog closes the access log file, if one is open
+func (s *Server) closeAccessLog() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accessLogFile == nil {
+		return nil
+	}
+	err := s.accessLogFile.Close()
+	s.accessLogFile = nil
+	return err
+}
+
+// switchWriter forwards writes to Writer, which may be replaced before the server starts serving
+type switchWriter struct {
+	io.Writer
+}
+
+// clientIPOptions maps the trusted proxy settings in the config to middleware options
+func (s *Server) clientIPOptions() middleware.ClientIPOptions {
+	if s.config == nil {
+		return middleware.ClientIPOptions{}
+	}
+	return middleware.ClientIPOptions{TrustedProxies: s.config.TrustedProxies}
+}
+
+// listenOptions maps the listener settings in the config to listener options
+func (s *Server) listenOptions() listener.Options {
+	if s.config == nil {
+		return listener.Options{}
+	}
+	return listener.Options{UnixSocketMode: s.config.UnixSocketMode}
+}
+
+// proxyOptions maps the trusted proxy settings in the config to PROXY protocol options
+func (s *Server) proxyOptions() listener.ProxyOptions {
+	if s.config == nil {
+		return listener.ProxyOptions{}
+	}
+	return listener.ProxyOptions{
+		Trusted:       s.config.TrustedProxies,
+		HeaderTimeout: s.config.ReadHeaderTimeout,
+	}
+}
+
+// SetLogLevel exposes level through the admin endpoints so it can be changed at runtime
+// It must be called before Start, and level should be the one the server's logger is built with
+func (s *Server) SetLogLevel(level *slog.LevelVar) {
+	s.logLevel = level
+}
+
+// Health returns the checker backing /livez and /readyz so callers can register checks
+func (s *Server) Health() *health.Checker {
+	return s.health
+}
+
+// newHTTPServer creates an http.Server with the configured timeouts and header limit
+func (s *Server) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       config.DefaultReadTimeout,
+		ReadHeaderTimeout: config.DefaultReadHeaderTimeout,
+		WriteTimeout:      config.DefaultWriteTimeout,
+		IdleTimeout:       config.DefaultIdleTimeout,
+		MaxHeaderBytes:    config.DefaultMaxHeaderBytes,
+	}
+	if s.config == nil {
+		return srv
+	}
+
+	// Zero values keep the defaults so partially filled configs stay usable
+	setIfPositive(&srv.ReadTimeout, s.config.ReadTimeout)
+	setIfPositive(&srv.ReadHeaderTimeout, s.config.ReadHeaderTimeout)
+	setIfPositive(&srv.WriteTimeout, s.config.WriteTimeout)
+	setIfPositive(&srv.IdleTimeout, s.config.IdleTimeout)
+	setIfPositive(&srv.MaxHeaderBytes, s.config.MaxHeaderBytes)
+	srv.ReadHeaderTimeout = min(srv.ReadHeaderTimeout, srv.ReadTimeout)
+
+	// Zero HTTP/2 values keep the net/http defaults
+	srv.HTTP2 = &http.HTTP2Config{
+		MaxConcurrentStreams: s.config.HTTP2MaxConcurrentStreams,
+		MaxReadFrameSize:     s.config.HTTP2MaxReadFrameSize,
+	}
+	return srv
+}
+
+// enableH2C lets srv accept HTTP/2 cleartext by prior knowledge and by the HTTP/1.1 Upgrade mechanism
+func (s *Server) enableH2C(srv *http.Server) {
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+
+	upgrader := newH2CUpgrader(srv.Handler, &http2.Server{
+		MaxConcurrentStreams: uint32(srv.HTTP2.MaxConcurrentStreams),
+		MaxReadFrameSize:     uint32(srv.HTTP2.MaxReadFrameSize),
+		IdleTimeout:          srv.IdleTimeout,
+	})
+	srv.Handler = upgrader
+	// Upgraded connections were hijacked, so Shutdown cannot drain them and they are closed instead
+	srv.RegisterOnShutdown(upgrader.closeAll)
+}
+
+// setIfPositive overwrites dst with v when v is greater than zero
+func setIfPositive[T time.Duration | int](dst *T, v T) {
+	if v > 0 {
+		*dst = v
+	}
+}
+
+// shutdownTimeout returns how long shutdown waits for in-flight requests
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.config != nil && s.config.ShutdownTimeout > 0 {
+		return s.config.ShutdownTimeout
+	}
+	return config.DefaultShutdownTimeout
+}
+
+// httpServers returns every http.Server managed by s
+func (s *Server) httpServers() []*http.Server {
+	servers := make([]*http.Server, 0, len(s.entries))
+	for _, e := range s.entries {
+		servers = append(servers, e.server)
+	}
+	return servers
+}
+
+// Start binds every listener, serves until ctx is cancelled and then shuts down gracefully
+// A listener that fails to bind or serve stops the server and its error is returned immediately
+// Start may only be called once per Server
+func (s *Server) Start(ctx context.Context) error {
+	// The access log file is opened first so an unwritable path stops startup
+	if err := s.openAccessLog(); err != nil {
+		return err
+	}
+	// Until every listener is bound, errors return here rather than through shutdown, so close the file on the way out
+	bound := false
+	defer func() {
+		if !bound {
+			_ = s.closeAccessLog()
+		}
+	}()
+
+	// Mock routes are read before binding so an invalid file stops startup
+	if err := s.loadMocks(); err != nil {
+		return err
+	}
 
 	// Add routes to the muxer
 	s.logger.Debug("setting up routes")
-	s.SetupRoutes()
+	if err := s.SetupRoutes(); err != nil {
+		return err
+	}
 
-	// Starting server in a goroutine
-	go func() {
-		s.logger.Info("starting server", "port", s.port)
-		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.logger.Error("server failed to start", "error", err)
+	// Load certificates and bind synchronously so errors such as a port already in use reach the caller
+	listeners := make([]net.Listener, 0, len(s.entries))
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
 		}
-	}()
+	}
+	for _, e := range s.entries {
+		if err := e.loadTLS(); err != nil {
+			closeAll()
+			return err
+		}
+		ln, err := listener.Listen(e.server.Addr, s.listenOptions())
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("listener %q: listen on %s: %w", e.name, e.server.Addr, err)
+		}
+		if e.proxyProtocol {
+			ln = listener.NewProxyListener(ln, s.proxyOptions())
+		}
+		listeners = append(listeners, ln)
+	}
 
-	// Block until a signal is received
-	<-stopChan
-	s.logger.Info("shutting down server")
+	var http3Conn net.PacketConn
+	if s.http3 != nil {
+		conn, err := s.listenHTTP3(s.server.TLSConfig)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		http3Conn = conn
+	}
+
+	s.mu.Lock()
+	for i, e := range s.entries {
+		e.ln = listeners[i]
+	}
+	s.http3Conn = http3Conn
+	s.mu.Unlock()
+	bound = true
+	close(s.ready)
 
-	// Create a deadline to wait for this is the duration the server will wait for existing connections to finish
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Pick up edits to the mock routes file until the server stops
+	if s.mocks != nil && s.config.MockRoutesReloadInterval > 0 {
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		defer stopWatch()
+		go s.mocks.Watch(watchCtx, s.config.MockRoutesReloadInterval)
+	}
+
+	// Serve each listener in its own goroutine
+	serveErrs := make(chan error, len(s.entries)+1)
+	if http3Conn != nil {
+		go func() {
+			s.logger.Info("starting server", "listener", MainListener, "addr", http3Conn.LocalAddr().String(), "protocol", "h3")
+			err := s.http3.Serve(http3Conn)
+			if errors.Is(err, http.ErrServerClosed) || errors.Is(err, quic.ErrServerClosed) {
+				err = nil
+			} else if err != nil {
+				err = fmt.Errorf("http3: serve on %s: %w", http3Conn.LocalAddr(), err)
+			}
+			serveErrs <- err
+		}()
+	}
+	for i, e := range s.entries {
+		go func() {
+			s.logger.Info("starting server", "listener", e.name, "addr", listeners[i].Addr().String(), "tls", e.server.TLSConfig != nil)
+			var err error
+			if e.server.TLSConfig != nil {
+				err = e.server.ServeTLS(listeners[i], "", "")
+			} else {
+				err = e.server.Serve(listeners[i])
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			} else if err != nil {
+				err = fmt.Errorf("listener %q: serve on %s: %w", e.name, listeners[i].Addr(), err)
+			}
+			serveErrs <- err
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		s.logger.Info("shutting down server")
+	case err := <-serveErrs:
+		if err == nil {
+			// Shutdown was called directly, wait for it to finish
+			<-s.shutdownDone
+			return s.shutdownErr
+		}
+		s.logger.Error("server failed", "error", err)
+		return errors.Join(err, s.Shutdown(context.Background()))
+	}
+
+	// The parent context is already cancelled, so shutdown only uses its own deadline
+	return s.Shutdown(context.Background())
+}
+
+// Ready returns a channel that is closed once every listener is bound
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr returns the bound address of the main listener, or nil before Start has bound it
+// With port 0 this reports the port chosen by the operating system
+func (s *Server) Addr() net.Addr {
+	return s.ListenerAddr(MainListener)
+}
+
+// AdminAddr returns the bound address of the admin listener, or nil if there is none
+func (s *Server) AdminAddr() net.Addr {
+	return s.ListenerAddr(AdminListener)
+}
+
+// ListenerAddr returns the bound address of the named listener, or nil if it is unknown or not bound yet
+func (s *Server) ListenerAddr(name string) net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.name == name && e.ln != nil {
+			return e.ln.Addr()
+		}
+	}
+	return nil
+}
+
+// loadTLS loads the listener certificate so the server serves TLS, a no-op without one
+func (e *listenerEntry) loadTLS() error {
+	if e.certFile == "" && e.keyFile == "" {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+	if err != nil {
+		return fmt.Errorf("listener %q: load TLS certificate: %w", e.name, err)
+	}
+	e.server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return nil
+}
+
+// Shutdown fails readiness, waits for the drain delay and gracefully stops every listener
+// In-flight requests get SHUTDOWN_TIMEOUT after the drain delay ends, and cancelling ctx cuts both short
+// Calling Shutdown more than once returns the first result
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+		close(s.shutdownDone)
+	})
+	<-s.shutdownDone
+	return s.shutdownErr
+}
+
+// shutdown performs the work behind Shutdown
+func (s *Server) shutdown(ctx context.Context) error {
+	// Fail readiness first and give load balancers time to stop sending traffic
+	s.health.SetDraining()
+	if s.config != nil && s.config.ShutdownDrainDelay > 0 {
+		s.logger.Info("draining before shutdown", "delay", s.config.ShutdownDrainDelay)
+		select {
+		case <-time.After(s.config.ShutdownDrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	// The deadline starts after draining so the delay does not eat into the time in-flight requests get
+	ctx, cancel := context.WithTimeout(ctx, s.shutdownTimeout())
 	defer cancel()
 
-	// Shutdown the server
-	if err := s.server.Shutdown(ctx); err != nil {
-		s.logger.Error("server shutdown failed", "error", err)
-		return err
+	// Shutdown all servers concurrently so they share the same deadline
+	errs := make(chan error, len(s.httpServers())+1)
+	if s.http3 != nil {
+		go func() {
+			// http3.Server leaves the UDP socket passed to Serve open, so release it once the server has stopped
+			err := s.http3.Shutdown(ctx)
+			s.mu.Lock()
+			if s.http3Conn != nil {
+				err = errors.Join(err, s.http3Conn.Close())
+			}
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Error("server shutdown failed", "protocol", "h3", "error", err)
+			}
+			errs <- err
+		}()
+	}
+	for _, srv := range s.httpServers() {
+		go func() {
+			if err := srv.Shutdown(ctx); err != nil {
+				s.logger.Error("server shutdown failed", "addr", srv.Addr, "error", err)
+				errs <- err
+				return
+			}
+			errs <- nil
+		}()
+	}
+
+	var shutdownErr error
+	for range s.httpServers() {
+		shutdownErr = errors.Join(shutdownErr, <-errs)
+	}
+	if s.http3 != nil {
+		shutdownErr = errors.Join(shutdownErr, <-errs)
+	}
+	shutdownErr = errors.Join(shutdownErr, s.closeAccessLog())
+	if shutdownErr != nil {
+		return shutdownErr
 	}
 	s.logger.Info("server exited properly")
 	return nil
 }
 
-// SetupRoutes sets up the server routes
-func (s *Server) SetupRoutes() {
+// SetupRoutes mounts the configured route groups on every listener
+// Mock routes are mounted after the built-in ones so a mock pattern that conflicts with one is returned as an error
+func (s *Server) SetupRoutes() error {
+	for _, e := range s.entries {
+		for _, group := range e.routes {
+			switch group {
+			case RoutesAPI:
+				s.mountAPI(e.mux)
+			case RoutesHealth:
+				s.mountHealth(e.mux)
+			case RoutesMetrics:
+				s.mountMetrics(e.mux)
+			case RoutesAdmin:
+				s.mountAdmin(e.mux)
+			case RoutesMock:
+				// Mounted below, once the built-in routes are in place
+			default:
+				s.logger.Warn("unknown route group", "listener", e.name, "routes", group)
+			}
+		}
+		if slices.Contains(e.routes, RoutesMock) {
+			if err := s.mountMock(e.mux); err != nil {
+				return fmt.Errorf("listener %q: mock routes: %w", e.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mountAPI registers the API endpoints on mux
+func (s *Server) mountAPI(mux *http.ServeMux) {
 	path := "/api/v1"
-	s.muxer.HandleFunc(
-		fmt.Sprintf(
-			"%s %s/echo",
-			http.MethodPost,
-			path,
-		), handlers.EchoHandler,
+	echoPattern := fmt.Sprintf(
+		"%s %s/echo",
+		http.MethodPost,
+		path,
 	)
-	s.muxer.HandleFunc("GET /health", handlers.HealthHandler)
-	s.muxer.Handle("GET /metrics", promhttp.Handler())
+	var echoHandler http.Handler = http.HandlerFunc(handlers.EchoHandler)
+	if s.config != nil && s.config.RequestDecompression {
+		echoHandler = middleware.DecompressMiddleware(middleware.DecompressOptions{
+			MaxBytes:   s.routeMaxBodyBytes(echoPattern),
+			Rejections: s.metrics.Rejections,
+		})(echoHandler)
+	}
+	mux.Handle(echoPattern, echoHandler)
+}
+
+// mountHealth registers the health and probe endpoints on mux
+func (s *Server) mountHealth(mux *http.ServeMux) {
+	mux.HandleFunc("GET /health", handlers.HealthHandler(s.health))
+	mux.HandleFunc("GET /livez", handlers.LivezHandler(s.health))
+	mux.HandleFunc("GET /readyz", handlers.ReadyzHandler(s.health))
+}
+
+// mountMetrics registers /metrics on mux unless metrics are disabled
+func (s *Server) mountMetrics(mux *http.ServeMux) {
+	if s.config != nil && s.config.MetricsDisabled {
+		return
+	}
+	metricsHandler := promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{Registry: s.registry})
+	mux.Handle("GET /metrics", middleware.OperationalAuthMiddleware(s.metricsCredentials())(metricsHandler))
+}
+
+// mountAdmin registers the /admin/ endpoints on mux, guarded by ADMIN_TOKEN
+// They change or reveal the running configuration, so they are left unmounted when no token is configured
+func (s *Server) mountAdmin(mux *http.ServeMux) {
+	if s.config == nil || s.config.AdminToken == "" {
+		return
+	}
+	auth := middleware.OperationalAuthMiddleware(middleware.OperationalCredentials{Token: s.config.AdminToken})
+
+	mux.Handle("GET /admin/v1/config", auth(handlers.ConfigHandler(s.config, middleware.KeyID)))
+	if s.logLevel != nil {
+		mux.Handle("GET /admin/v1/loglevel", auth(handlers.LogLevelHandler(s.logLevel)))
+		mux.Handle("PUT /admin/v1/loglevel", auth(handlers.SetLogLevelHandler(s.logLevel, s.logger)))
+	}
+}
+
+// mountMock registers the mock route patterns on mux
+func (s *Server) mountMock(mux *http.ServeMux) error {
+	if s.mocks == nil {
+		return nil
+	}
+	return s.mocks.Mount(mux)
+}
+
+// loadMocks reads MockRoutesFile if one is configured and not yet loaded
+func (s *Server) loadMocks() error {
+	if s.mocks != nil || s.config == nil || s.config.MockRoutesFile == "" {
+		return nil
+	}
+	mocks, err := mock.New(s.config.MockRoutesFile, s.logger)
+	if err != nil {
+		return fmt.Errorf("mock routes: %w", err)
+	}
+	s.mocks = mocks
+	return nil
+}
+
+// routeMaxBodyBytes returns the body limit that applies to the given route pattern
+func (s *Server) routeMaxBodyBytes(pattern string) int64 {
+	if limit, ok := s.config.RouteMaxBodyBytes[pattern]; ok {
+		return limit
+	}
+	return s.config.MaxBodyBytes
+}
+
+// metricsCredentials returns the credentials guarding /metrics
+func (s *Server) metricsCredentials() middleware.OperationalCredentials {
+	if s.config == nil {
+		return middleware.OperationalCredentials{}
+	}
+	return middleware.OperationalCredentials{
+		Token:    s.config.MetricsToken,
+		Username: s.config.MetricsUsername,
+		Password: s.config.MetricsPassword,
+	}
 }
@@ -1,14 +1,33 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"io"
 	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/lkendrickd/echo-server/internal/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewServer(t *testing.T) {
@@ -123,7 +142,9 @@ func TestSetupRoutes(t *testing.T) {
 	}
 
 	s := NewServer(logger, mux, ":8080", cfg)
-	s.SetupRoutes()
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
 
 	tests := []struct {
 		name       string
@@ -175,7 +196,9 @@ func TestSetupRoutes_MethodNotAllowed(t *testing.T) {
 	}
 
 	s := NewServer(logger, mux, ":8080", cfg)
-	s.SetupRoutes()
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
 
 	tests := []struct {
 		name   string
@@ -224,7 +247,9 @@ func TestSetupRoutes_NotFound(t *testing.T) {
 	}
 
 	s := NewServer(logger, mux, ":8080", cfg)
-	s.SetupRoutes()
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
 
 	tests := []struct {
 		name   string
@@ -256,3 +281,1167 @@ func TestSetupRoutes_NotFound(t *testing.T) {
 		})
 	}
 }
+
+func TestListenerNamesMatchConfig(t *testing.T) {
+	// config validates listener settings against its own lists, which must stay in step with the server
+	groups := []string{RoutesAPI, RoutesHealth, RoutesMetrics, RoutesAdmin, RoutesMock}
+	if !slices.Equal(groups, config.RouteGroups) {
+		t.Errorf("config.RouteGroups = %v, want %v", config.RouteGroups, groups)
+	}
+
+	names := []string{MiddlewareMetrics, MiddlewareCompression, MiddlewareBodyLimit, MiddlewareAuth, MiddlewareCORS, MiddlewareAccessLog, "none"}
+	if !slices.Equal(names, config.ListenerMiddleware) {
+		t.Errorf("config.ListenerMiddleware = %v, want %v", config.ListenerMiddleware, names)
+	}
+}
+
+func TestNewServer_IndependentRegistries(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	first := NewServer(logger, http.NewServeMux(), ":8080", nil)
+	second := NewServer(logger, http.NewServeMux(), ":8081", nil)
+
+	if first.registry == second.registry {
+		t.Fatal("servers share a prometheus registry")
+	}
+
+	if err := first.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+	if err := second.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	// Traffic on the first server must not show up on the second
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	first.server.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	second.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if strings.Contains(rec.Body.String(), `path="/health"`) {
+		t.Error("second server exposed requests served by the first")
+	}
+}
+
+func TestSetupRoutes_MetricsCollectors(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+
+	s := NewServer(logger, mux, ":8080", nil)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, name := range []string{"go_goroutines", "process_start_time_seconds"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("metrics output missing %s", name)
+		}
+	}
+}
+
+func TestSetupRoutes_MetricsOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		token       string
+		wantPublic  int
+		wantAdmin   int
+		wantAdminOK bool
+	}{
+		{
+			name:       "metrics disabled",
+			cfg:        &config.Config{MetricsDisabled: true},
+			wantPublic: http.StatusNotFound,
+		},
+		{
+			name:       "metrics token required",
+			cfg:        &config.Config{MetricsToken: "scrape"},
+			wantPublic: http.StatusUnauthorized,
+		},
+		{
+			name:       "metrics token presented",
+			cfg:        &config.Config{MetricsToken: "scrape"},
+			token:      "scrape",
+			wantPublic: http.StatusOK,
+		},
+		{
+			name:        "metrics moved to admin port",
+			cfg:         &config.Config{AdminPort: "9090"},
+			wantPublic:  http.StatusNotFound,
+			wantAdmin:   http.StatusOK,
+			wantAdminOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mux := http.NewServeMux()
+
+			s := NewServer(logger, mux, ":8080", tt.cfg)
+			if err := s.SetupRoutes(); err != nil {
+				t.Fatalf("SetupRoutes() error = %v", err)
+			}
+
+			newRequest := func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+				if tt.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				return req
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, newRequest())
+			if rec.Code != tt.wantPublic {
+				t.Errorf("public /metrics status = %d, want %d", rec.Code, tt.wantPublic)
+			}
+
+			if (s.adminServer != nil) != tt.wantAdminOK {
+				t.Fatalf("admin server configured = %v, want %v", s.adminServer != nil, tt.wantAdminOK)
+			}
+			if !tt.wantAdminOK {
+				return
+			}
+
+			if s.adminServer.Addr != ":9090" {
+				t.Errorf("admin Addr = %q, want %q", s.adminServer.Addr, ":9090")
+			}
+
+			rec = httptest.NewRecorder()
+			s.adminServer.Handler.ServeHTTP(rec, newRequest())
+			if rec.Code != tt.wantAdmin {
+				t.Errorf("admin /metrics status = %d, want %d", rec.Code, tt.wantAdmin)
+			}
+
+			if got := len(s.httpServers()); got != 2 {
+				t.Errorf("httpServers() = %d servers, want 2", got)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_AdminLogLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config.Config
+		token      string
+		wantPublic int
+		wantAdmin  int
+	}{
+		{
+			name:       "unmounted without an admin token",
+			cfg:        &config.Config{},
+			wantPublic: http.StatusNotFound,
+		},
+		{
+			name:       "admin token required",
+			cfg:        &config.Config{AdminToken: "operator"},
+			wantPublic: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong admin token",
+			cfg:        &config.Config{AdminToken: "operator"},
+			token:      "scrape",
+			wantPublic: http.StatusUnauthorized,
+		},
+		{
+			name:       "admin token presented",
+			cfg:        &config.Config{AdminToken: "operator"},
+			token:      "operator",
+			wantPublic: http.StatusOK,
+		},
+		{
+			name:       "moved to admin port",
+			cfg:        &config.Config{AdminPort: "9090", AdminToken: "operator"},
+			token:      "operator",
+			wantPublic: http.StatusNotFound,
+			wantAdmin:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			logger := slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: level}))
+			mux := http.NewServeMux()
+
+			s := NewServer(logger, mux, ":8080", tt.cfg)
+			s.SetLogLevel(level)
+			if err := s.SetupRoutes(); err != nil {
+				t.Fatalf("SetupRoutes() error = %v", err)
+			}
+
+			handler := http.Handler(mux)
+			wantStatus := tt.wantPublic
+			if tt.wantAdmin != 0 {
+				handler, wantStatus = s.adminServer.Handler, tt.wantAdmin
+			}
+			send := func(method, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, "/admin/v1/loglevel", strings.NewReader(body))
+				if tt.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			if rec := send(http.MethodPut, `{"level":"debug"}`); rec.Code != wantStatus {
+				t.Fatalf("PUT status = %d, want %d", rec.Code, wantStatus)
+			}
+			wantLevel := slog.LevelInfo
+			if wantStatus == http.StatusOK {
+				wantLevel = slog.LevelDebug
+			}
+			if level.Level() != wantLevel {
+				t.Errorf("level = %v, want %v", level.Level(), wantLevel)
+			}
+
+			rec := send(http.MethodGet, "")
+			if rec.Code != wantStatus {
+				t.Fatalf("GET status = %d, want %d", rec.Code, wantStatus)
+			}
+			if wantStatus == http.StatusOK && strings.TrimSpace(rec.Body.String()) != `{"level":"debug"}` {
+				t.Errorf("GET body = %q, want the debug level", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_AdminConfig(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{Port: "8080", AdminToken: "operator", MetricsToken: "scrape"}
+
+	s := NewServer(logger, mux, ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/v1/config", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req.Header.Set("Authorization", "Bearer operator")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"PORT":{"value":"8080","source":"default"}`) {
+		t.Errorf("body = %s, want the PORT setting", body)
+	}
+	for _, secret := range []string{"operator", "scrape"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("body leaks %q", secret)
+		}
+	}
+}
+
+func TestSetupRoutes_MockRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	routes := "routes:\n  - method: GET\n    pattern: /users/{id}\n    body: user {id}\n"
+	if err := os.WriteFile(path, []byte(routes), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	s := NewServer(logger, mux, ":8080", &config.Config{MockRoutesFile: path})
+	if err := s.loadMocks(); err != nil {
+		t.Fatalf("loadMocks() error = %v", err)
+	}
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "mock route", method: http.MethodGet, path: "/users/7", wantStatus: http.StatusOK, wantBody: "user 7"},
+		{name: "mock method mismatch", method: http.MethodPost, path: "/users/7", wantStatus: http.StatusMethodNotAllowed},
+		{name: "built-in route", method: http.MethodGet, path: "/health", wantStatus: http.StatusOK, wantBody: `{"healthy":true}` + "\n"},
+		{name: "built-in method mismatch", method: http.MethodGet, path: "/api/v1/echo", wantStatus: http.StatusMethodNotAllowed},
+		{name: "unmatched", method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSetupRoutes_MockRouteConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if err := os.WriteFile(path, []byte("routes:\n  - method: GET\n    pattern: /health\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewServer(logger, http.NewServeMux(), ":8080", &config.Config{MockRoutesFile: path})
+	if err := s.loadMocks(); err != nil {
+		t.Fatalf("loadMocks() error = %v", err)
+	}
+
+	err := s.SetupRoutes()
+	if err == nil || !strings.Contains(err.Error(), `routes[0] "GET /health"`) || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("SetupRoutes() error = %v, want a conflict with the built-in /health", err)
+	}
+}
+
+func TestStart_InvalidMockRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	if err := os.WriteFile(path, []byte("routes:\n  - pattern: /a\n    status: 1000\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", &config.Config{MockRoutesFile: path})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start(context.Background()) }()
+
+	if err := waitStart(t, done); err == nil || !strings.Contains(err.Error(), "invalid status 1000") {
+		t.Errorf("Start() error = %v, want the mock routes error", err)
+	}
+}
+
+func TestNewServer_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{AccessLogEnabled: true, AccessLogSampleRate: 1, AccessLogFormat: "json"}
+
+	s := NewServer(logger, mux, ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	s.server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if !strings.Contains(buf.String(), `"route":"GET /health"`) {
+		t.Errorf("access log = %q, want record for GET /health", buf.String())
+	}
+}
+
+func TestNewServer_AdminListenerMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	cfg := &config.Config{AdminPort: "9090", AccessLogEnabled: true, AccessLogSampleRate: 1, AccessLogFormat: "json"}
+
+	s := NewServer(logger, http.NewServeMux(), ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.adminServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Error("admin listener response has no X-Request-ID")
+	}
+	if !strings.Contains(buf.String(), `"route":"GET /health"`) {
+		t.Errorf("access log = %q, want record for GET /health on the admin listener", buf.String())
+	}
+}
+
+func TestNewServer_RequestID(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+
+	s := NewServer(logger, mux, ":8080", &config.Config{RequestIDHeader: "X-Correlation-ID"})
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Correlation-ID", "upstream-1")
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Correlation-ID"); got != "upstream-1" {
+		t.Errorf("X-Correlation-ID = %q, want %q", got, "upstream-1")
+	}
+}
+
+func TestNewServer_RecoversPanics(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+
+	s := NewServer(logger, mux, ":8080", nil)
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("handler bug")
+	})
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	if got := testutil.ToFloat64(s.metrics.PanicCount); got != 1 {
+		t.Errorf("http_panics_total = %v, want 1", got)
+	}
+}
+
+func TestNewServer_BodyLimits(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{
+		MaxBodyBytes:      4,
+		RouteMaxBodyBytes: map[string]int64{"GET /health": 1},
+		RouteContentTypes: map[string][]string{"POST /api/v1/echo": {"application/json"}},
+	}
+
+	s := NewServer(logger, mux, ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		wantStatus  int
+	}{
+		{name: "small JSON body", body: "{}", contentType: "application/json", wantStatus: http.StatusOK},
+		{name: "oversized body", body: `{"a":1}`, contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "wrong content type", body: "hi", contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+
+			s.server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(s.metrics.Rejections.WithLabelValues("body_too_large")); got != 1 {
+		t.Errorf("body_too_large rejections = %v, want 1", got)
+	}
+}
+
+func TestNewServer_CORSPreflightBeforeAuth(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{
+		AuthEnabled:        true,
+		CORSAllowedOrigins: []string{"https://dash.example.com"},
+		CORSAllowedMethods: []string{"POST"},
+	}
+
+	s := NewServer(logger, mux, ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/echo", nil)
+	req.Header.Set("Origin", "https://dash.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+
+	s.server.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, "https://dash.example.com")
+	}
+}
+
+func TestNewServer_Compression(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{
+		CompressionEnabled:      true,
+		CompressionMinSize:      16,
+		CompressionContentTypes: []string{"application/json"},
+		RequestDecompression:    true,
+	}
+
+	s := NewServer(logger, mux, ":8080", cfg)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	payload := `{"message":"` + strings.Repeat("hello ", 20) + `"}`
+
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, _ = gw.Write([]byte(payload))
+	_ = gw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	s.server.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	body, _ := io.ReadAll(gr)
+	if string(body) != payload {
+		t.Errorf("echoed body = %q, want %q", body, payload)
+	}
+}
+
+func TestNewServer_DecompressedBodyLimit(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	s := NewServer(logger, mux, ":8080", &config.Config{MaxBodyBytes: 64, RequestDecompression: true})
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	// The compressed body fits the limit, the decoded one does not
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, _ = gw.Write([]byte(strings.Repeat("a", 1000)))
+	_ = gw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/echo", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	s.server.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if got := testutil.ToFloat64(s.metrics.Rejections.WithLabelValues("body_too_large")); got != 1 {
+		t.Errorf("body_too_large rejections = %v, want 1", got)
+	}
+}
+
+func TestSetupRoutes_HealthProbes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+
+	s := NewServer(logger, mux, ":8080", nil)
+	if err := s.SetupRoutes(); err != nil {
+		t.Fatalf("SetupRoutes() error = %v", err)
+	}
+
+	for _, path := range []string{"/health", "/livez", "/readyz"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s status = %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+
+	// Draining flips readiness and the legacy /health but not liveness
+	s.Health().SetDraining()
+
+	for _, path := range []string{"/health", "/readyz"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s while draining status = %d, want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/livez while draining status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestNewServer_ConfiguredLimits(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{
+		Port:              "8080",
+		AdminPort:         "9090",
+		ReadTimeout:       5 * time.Minute,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      10 * time.Minute,
+		IdleTimeout:       30 * time.Second,
+		MaxHeaderBytes:    8192,
+		ShutdownTimeout:   time.Minute,
+	}
+
+	s := NewServer(logger, http.NewServeMux(), ":8080", cfg)
+
+	// The admin listener shares the same limits as the public one
+	for _, srv := range s.httpServers() {
+		if srv.ReadTimeout != cfg.ReadTimeout {
+			t.Errorf("%s ReadTimeout = %v, want %v", srv.Addr, srv.ReadTimeout, cfg.ReadTimeout)
+		}
+		if srv.ReadHeaderTimeout != cfg.ReadHeaderTimeout {
+			t.Errorf("%s ReadHeaderTimeout = %v, want %v", srv.Addr, srv.ReadHeaderTimeout, cfg.ReadHeaderTimeout)
+		}
+		if srv.WriteTimeout != cfg.WriteTimeout {
+			t.Errorf("%s WriteTimeout = %v, want %v", srv.Addr, srv.WriteTimeout, cfg.WriteTimeout)
+		}
+		if srv.IdleTimeout != cfg.IdleTimeout {
+			t.Errorf("%s IdleTimeout = %v, want %v", srv.Addr, srv.IdleTimeout, cfg.IdleTimeout)
+		}
+		if srv.MaxHeaderBytes != cfg.MaxHeaderBytes {
+			t.Errorf("%s MaxHeaderBytes = %d, want %d", srv.Addr, srv.MaxHeaderBytes, cfg.MaxHeaderBytes)
+		}
+	}
+
+	if got := s.shutdownTimeout(); got != time.Minute {
+		t.Errorf("shutdownTimeout() = %v, want %v", got, time.Minute)
+	}
+
+	// Unset values keep the defaults
+	s = NewServer(logger, http.NewServeMux(), ":8080", &config.Config{ReadTimeout: time.Second})
+	if s.server.ReadHeaderTimeout != time.Second {
+		t.Errorf("ReadHeaderTimeout = %v, want capped at ReadTimeout %v", s.server.ReadHeaderTimeout, time.Second)
+	}
+	if s.server.MaxHeaderBytes != config.DefaultMaxHeaderBytes {
+		t.Errorf("MaxHeaderBytes = %d, want default %d", s.server.MaxHeaderBytes, config.DefaultMaxHeaderBytes)
+	}
+	if got := s.shutdownTimeout(); got != config.DefaultShutdownTimeout {
+		t.Errorf("shutdownTimeout() = %v, want default %v", got, config.DefaultShutdownTimeout)
+	}
+}
+
+// startServer runs s.Start in the background and waits until its listeners are bound
+func startServer(t *testing.T, ctx context.Context, s *Server) <-chan error {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start(ctx) }()
+
+	select {
+	case <-s.Ready():
+	case err := <-done:
+		t.Fatalf("Start() returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not become ready")
+	}
+	return done
+}
+
+// waitStart waits for Start to return and reports its error
+func waitStart(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() did not return")
+		return nil
+	}
+}
+
+func TestStart_ContextCancellation(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{AdminPort: "0"}
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", cfg)
+
+	if s.Addr() != nil {
+		t.Errorf("Addr() = %v before Start, want nil", s.Addr())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+
+	addr := s.Addr()
+	if addr == nil || addr.(*net.TCPAddr).Port == 0 {
+		t.Fatalf("Addr() = %v, want a bound port", addr)
+	}
+	if s.AdminAddr() == nil {
+		t.Fatal("AdminAddr() = nil with an admin port configured")
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/health")
+	if err != nil {
+		t.Fatalf("GET /health: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v, want nil", err)
+	}
+	if !s.Health().Draining() {
+		t.Error("readiness not failed after shutdown")
+	}
+}
+
+func TestStart_ListenError(t *testing.T) {
+	// Occupy a port so the server cannot bind it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer ln.Close()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewServer(logger, http.NewServeMux(), ln.Addr().String(), nil)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Start(context.Background()) }()
+
+	err = waitStart(t, done)
+	if err == nil {
+		t.Fatal("Start() error = nil, want address in use error")
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("Start() error = %v, want a *net.OpError", err)
+	}
+}
+
+func TestStart_AccessLogFile(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	newConfig := func(path string) *config.Config {
+		return &config.Config{AccessLogEnabled: true, AccessLogSampleRate: 1, AccessLogFormat: "common", AccessLogFile: path}
+	}
+
+	t.Run("lines written to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "access.log")
+		s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", newConfig(path))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := startServer(t, ctx, s)
+		resp, err := http.Get("http://" + s.Addr().String() + "/health")
+		if err != nil {
+			t.Fatalf("GET /health: %v", err)
+		}
+		_ = resp.Body.Close()
+		cancel()
+		if err := waitStart(t, done); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if !strings.Contains(string(data), `"GET /health HTTP/1.1" 200`) {
+			t.Errorf("access log = %q, want the /health request", data)
+		}
+	})
+
+	t.Run("unwritable path stops startup", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "access.log")
+		s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", newConfig(path))
+
+		done := make(chan error, 1)
+		go func() { done <- s.Start(context.Background()) }()
+		if err := waitStart(t, done); err == nil || !strings.Contains(err.Error(), "access log") {
+			t.Errorf("Start() error = %v, want the access log error", err)
+		}
+	})
+
+	t.Run("file closed when a listener fails to bind", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("net.Listen: %v", err)
+		}
+		defer ln.Close()
+
+		s := NewServer(logger, http.NewServeMux(), ln.Addr().String(), newConfig(filepath.Join(t.TempDir(), "access.log")))
+		done := make(chan error, 1)
+		go func() { done <- s.Start(context.Background()) }()
+		if err := waitStart(t, done); err == nil {
+			t.Fatal("Start() error = nil, want address in use error")
+		}
+		if s.accessLogFile != nil {
+			t.Error("access log file left open after Start failed")
+		}
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", nil)
+
+	done := startServer(t, context.Background(), s)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v after Shutdown, want nil", err)
+	}
+
+	// Repeated calls return the first result
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown() error = %v", err)
+	}
+
+	if _, err := net.DialTimeout("tcp", s.Addr().String(), time.Second); err == nil {
+		t.Error("listener still accepting connections after Shutdown")
+	}
+}
+
+func TestShutdown_DrainDelayKeepsTimeout(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	cfg := &config.Config{ShutdownDrainDelay: 200 * time.Millisecond, ShutdownTimeout: 300 * time.Millisecond}
+	s := NewServer(logger, mux, "127.0.0.1:0", cfg)
+
+	// The request outlives the shutdown timeout but not the drain delay plus the timeout
+	started := make(chan struct{})
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(400 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + s.Addr().String() + "/slow")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v, want nil", err)
+	}
+	if res := <-results; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request = %q, %v, want it to complete", res.body, res.err)
+	}
+}
+
+func TestStart_UnixSocket(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "echo.sock")
+	s := NewServer(logger, http.NewServeMux(), "unix:"+path, &config.Config{UnixSocketMode: 0o600})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+
+	if _, ok := s.Addr().(*net.UnixAddr); !ok {
+		t.Errorf("Addr() = %T, want *net.UnixAddr", s.Addr())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://echo/health")
+	if err != nil {
+		t.Fatalf("GET /health over unix socket: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	cancel()
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file not removed on shutdown, stat error = %v", err)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and returns the cert and key paths
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestStart_MultipleListeners(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := &config.Config{
+		AuthEnabled: true,
+		Listeners: []config.ListenerConfig{
+			{Name: "secure", Addr: "127.0.0.1:0", TLSCertFile: certFile, TLSKeyFile: keyFile},
+			{Name: "internal", Addr: "127.0.0.1:0", Routes: []string{RoutesAPI, RoutesMetrics}, Middleware: []string{MiddlewareMetrics}},
+		},
+	}
+	s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startServer(t, ctx, s)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	get := func(method, url string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, url, strings.NewReader(`{}`))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	main := "http://" + s.Addr().String()
+	secure := "https://" + s.ListenerAddr("secure").String()
+	internal := "http://" + s.ListenerAddr("internal").String()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{name: "main requires auth", method: http.MethodPost, url: main + "/api/v1/echo", wantStatus: http.StatusUnauthorized},
+		{name: "secure serves TLS with the full chain", method: http.MethodPost, url: secure + "/api/v1/echo", wantStatus: http.StatusUnauthorized},
+		{name: "secure mounts health by default", method: http.MethodGet, url: secure + "/health", wantStatus: http.StatusOK},
+		{name: "internal skips auth", method: http.MethodPost, url: internal + "/api/v1/echo", wantStatus: http.StatusOK},
+		{name: "internal mounts metrics", method: http.MethodGet, url: internal + "/metrics", wantStatus: http.StatusOK},
+		{name: "internal does not mount health", method: http.MethodGet, url: internal + "/health", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(tt.method, tt.url); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+
+	// Idle HTTP/2 connections would otherwise hold graceful shutdown open until the client notices GOAWAY
+	client.CloseIdleConnections()
+	cancel()
+	if err := waitStart(t, done); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+	for _, name := range []string{MainListener, "secure", "internal"} {
+		if _, err := net.DialTimeout("tcp", s.ListenerAddr(name).String(), time.Second); err == nil {
+			t.Errorf("listener %q still accepting connections after shutdown", name)
+		}
+	}
+}
+
+func TestStart_ListenerFailsFast(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer occupied.Close()
+
+	// Reserve a free port for the main listener so it can be checked after the failure
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	mainAddr := free.Addr().String()
+	_ = free.Close()
+
+	tests := []struct {
+		name     string
+		listener config.ListenerConfig
+	}{
+		{name: "address in use", listener: config.ListenerConfig{Name: "busy", Addr: occupied.Addr().String()}},
+		{name: "missing certificate", listener: config.ListenerConfig{Name: "tls", Addr: "127.0.0.1:0", TLSCertFile: "missing.pem", TLSKeyFile: "missing.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			cfg := &config.Config{Listeners: []config.ListenerConfig{tt.listener}}
+			s := NewServer(logger, http.NewServeMux(), mainAddr, cfg)
+
+			done := make(chan error, 1)
+			go func() { done <- s.Start(context.Background()) }()
+
+			err := waitStart(t, done)
+			if err == nil || !strings.Contains(err.Error(), tt.listener.Name) {
+				t.Fatalf("Start() error = %v, want error naming listener %q", err, tt.listener.Name)
+			}
+
+			// Listeners bound before the failure are released
+			ln, err := net.Listen("tcp", mainAddr)
+			if err != nil {
+				t.Fatalf("main address still bound after failed Start: %v", err)
+			}
+			_ = ln.Close()
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a running server
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStart_ProxyProtocolClientIP(t *testing.T) {
+	tests := []struct {
+		name          string
+		proxyHeader   string
+		forwardedFor  string
+		trusted       []netip.Prefix
+		wantRemoteIP  string
+		wantConnError bool
+	}{
+		{
+			name:         "proxy header sets client",
+			proxyHeader:  "PROXY TCP4 192.0.2.10 198.51.100.1 56324 80\r\n",
+			forwardedFor: "203.0.113.9",
+			trusted:      []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			wantRemoteIP: "192.0.2.10",
+		},
+		{
+			name:         "forwarded header honored from trusted proxied source",
+			proxyHeader:  "PROXY TCP4 10.1.2.3 198.51.100.1 56324 80\r\n",
+			forwardedFor: "203.0.113.9",
+			trusted:      []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("10.0.0.0/8")},
+			wantRemoteIP: "203.0.113.9",
+		},
+		{
+			name:          "untrusted peer rejected",
+			proxyHeader:   "PROXY TCP4 192.0.2.10 198.51.100.1 56324 80\r\n",
+			trusted:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			wantConnError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs syncBuffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			cfg := &config.Config{
+				ProxyProtocol:       true,
+				TrustedProxies:      tt.trusted,
+				AccessLogEnabled:    true,
+				AccessLogSampleRate: 1,
+				AccessLogFormat:     "json",
+			}
+			s := NewServer(logger, http.NewServeMux(), "127.0.0.1:0", cfg)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := startServer(t, ctx, s)
+			defer func() {
+				cancel()
+				_ = waitStart(t, done)
+			}()
+
+			conn, err := net.Dial("tcp", s.Addr().String())
+			if err != nil {
+				t.Fatalf("net.Dial: %v", err)
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			request := tt.proxyHeader + "GET /health HTTP/1.1\r\nHost: echo\r\nConnection: close\r\n"
+			if tt.forwardedFor != "" {
+				request += "X-Forwarded-For: " + tt.forwardedFor + "\r\n"
+			}
+			_, _ = conn.Write([]byte(request + "\r\n"))
+
+			resp, err := io.ReadAll(conn)
+			if tt.wantConnError {
+				if err == nil && strings.HasPrefix(string(resp), "HTTP/1.1 200") {
+					t.Error("untrusted peer was served")
+				}
+				return
+			}
+			if !strings.HasPrefix(string(resp), "HTTP/1.1 200") {
+				t.Fatalf("response = %q, err = %v", resp, err)
+			}
+
+			want := `"remote_ip":"` + tt.wantRemoteIP + `"`
+			if !strings.Contains(logs.String(), want) {
+				t.Errorf("access log = %q, want %s", logs.String(), want)
+			}
+		})
+	}
+}